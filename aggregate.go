@@ -0,0 +1,278 @@
+package syro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// LogAggregation is the number of logs in the time bucket, for the values
+// of the grouped fields. Fields which are not grouped are empty.
+type LogAggregation struct {
+	Bucket time.Time `json:"bucket"`           // Start of the time bucket, zero if the logs are not bucketed
+	Level  LogLevel  `json:"level,omitempty"`  // Set if grouped by the level
+	Source string    `json:"source,omitempty"` // Set if grouped by the source
+	Event  string    `json:"event,omitempty"`  // Set if grouped by the event
+	Count  int64     `json:"count"`
+}
+
+// LogAggregator is implemented by the loggers which can count the logs
+// without returning them.
+type LogAggregator interface {
+	// AggregateLogs counts the logs which match the filter, grouped by the
+	// fields ("level", "source" or "event") and by the time buckets of
+	// the provided size. The buckets are aligned to the unix epoch. If
+	// the bucket is 0, all of the logs are in the same bucket. The
+	// pagination of the filter is ignored.
+	AggregateLogs(filter LogFilter, groupBy []string, bucket time.Duration) ([]LogAggregation, error)
+}
+
+var logGroupByFields = []string{"level", "source", "event"}
+
+func validateAggregation(groupBy []string, bucket time.Duration) error {
+	for _, field := range groupBy {
+		if !slices.Contains(logGroupByFields, field) {
+			return fmt.Errorf("invalid group by field: %v", field)
+		}
+	}
+
+	if bucket < 0 || (bucket > 0 && bucket < time.Millisecond) {
+		return errors.New("bucket has to be at least 1ms")
+	}
+
+	return nil
+}
+
+// bucketStart returns the start of the bucket of the time, aligned to the
+// unix epoch in the same way as in the mongo pipeline.
+func bucketStart(t time.Time, bucket time.Duration) time.Time {
+	if bucket <= 0 {
+		return time.Time{}
+	}
+
+	ms := t.UnixMilli()
+	return time.UnixMilli(ms - ms%bucket.Milliseconds()).UTC()
+}
+
+// aggregateLogs counts the logs in Go. Used by the loggers which filter the
+// logs in Go.
+func aggregateLogs(logs []Log, groupBy []string, bucket time.Duration) []LogAggregation {
+	counts := make(map[LogAggregation]int64)
+
+	for _, log := range logs {
+		key := LogAggregation{Bucket: bucketStart(log.Timestamp, bucket)}
+
+		for _, field := range groupBy {
+			switch field {
+			case "level":
+				key.Level = log.Level
+			case "source":
+				key.Source = log.Source
+			case "event":
+				key.Event = log.Event
+			}
+		}
+
+		counts[key]++
+	}
+
+	result := make([]LogAggregation, 0, len(counts))
+	for key, count := range counts {
+		key.Count = count
+		result = append(result, key)
+	}
+
+	sortAggregations(result)
+	return result
+}
+
+// sortAggregations sorts the results by the bucket and the grouped fields.
+func sortAggregations(aggs []LogAggregation) {
+	sort.Slice(aggs, func(i, j int) bool {
+		a, b := aggs[i], aggs[j]
+		switch {
+		case !a.Bucket.Equal(b.Bucket):
+			return a.Bucket.Before(b.Bucket)
+		case a.Level != b.Level:
+			return a.Level < b.Level
+		case a.Source != b.Source:
+			return a.Source < b.Source
+		default:
+			return a.Event < b.Event
+		}
+	})
+}
+
+// AggregateLogs counts the logs with an aggregation pipeline.
+func (lg *MongoLogger) AggregateLogs(filter LogFilter, groupBy []string, bucket time.Duration) ([]LogAggregation, error) {
+	if err := validateAggregation(groupBy, bucket); err != nil {
+		return nil, err
+	}
+
+	match, err := logFilterQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	group := bson.M{}
+	for _, field := range groupBy {
+		group[field] = "$" + field
+	}
+
+	if bucket > 0 {
+		ms := bson.M{"$toLong": "$timestamp"}
+		group["bucket"] = bson.M{"$toDate": bson.M{"$subtract": bson.A{ms, bson.M{"$mod": bson.A{ms, bucket.Milliseconds()}}}}}
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{"_id": group, "count": bson.M{"$sum": 1}}},
+	}
+
+	ctx := context.Background()
+	cur, err := lg.Coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var docs []struct {
+		ID struct {
+			Bucket time.Time `bson:"bucket"`
+			Level  LogLevel  `bson:"level"`
+			Source string    `bson:"source"`
+			Event  string    `bson:"event"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	result := make([]LogAggregation, len(docs))
+	for i, doc := range docs {
+		result[i] = LogAggregation{
+			Bucket: doc.ID.Bucket,
+			Level:  doc.ID.Level,
+			Source: doc.ID.Source,
+			Event:  doc.ID.Event,
+			Count:  doc.Count,
+		}
+	}
+
+	sortAggregations(result)
+	return result, nil
+}
+
+// AggregateLogs counts the logs in the buffer.
+func (lg *MemoryLogger) AggregateLogs(filter LogFilter, groupBy []string, bucket time.Duration) ([]LogAggregation, error) {
+	if err := validateAggregation(groupBy, bucket); err != nil {
+		return nil, err
+	}
+
+	filter.TimeseriesFilter = TimeseriesFilter{From: filter.From, To: filter.To}
+	logs, err := lg.FindLogs(filter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return aggregateLogs(logs, groupBy, bucket), nil
+}
+
+// AggregateLogs counts the logs in the files which overlap with the time
+// range of the filter.
+func (lg *FileLogger) AggregateLogs(filter LogFilter, groupBy []string, bucket time.Duration) ([]LogAggregation, error) {
+	if err := validateAggregation(groupBy, bucket); err != nil {
+		return nil, err
+	}
+
+	filter.TimeseriesFilter = TimeseriesFilter{From: filter.From, To: filter.To}
+	logs, err := lg.FindLogs(filter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return aggregateLogs(logs, groupBy, bucket), nil
+}
+
+// AggregateLogs counts the logs of the queryable sink.
+func (lg *MultiLogger) AggregateLogs(filter LogFilter, groupBy []string, bucket time.Duration) ([]LogAggregation, error) {
+	if lg.Queryable == nil {
+		return nil, errNoQueryableSink
+	}
+
+	aggregator, ok := lg.Queryable.(LogAggregator)
+	if !ok {
+		return nil, fmt.Errorf("%v logger does not support aggregations", lg.Queryable.Name())
+	}
+
+	return aggregator.AggregateLogs(filter, groupBy, bucket)
+}
+
+// RequestLogsAggregation counts the logs which match the URL params. The
+// filter uses the same params as RequestLogs. The fields are set with
+// group_by (comma separated or repeated) and the size of the time
+// buckets with bucket (e.g. "1h"), so the errors per hour per source
+// are requested with ?min_level=error&group_by=source&bucket=1h
+func RequestLogsAggregation(l Logger, urlPath string) ([]LogAggregation, error) {
+	if l == nil {
+		return nil, errors.New("logger is nil")
+	}
+
+	aggregator, ok := l.(LogAggregator)
+	if !ok {
+		return nil, fmt.Errorf("%v logger does not support aggregations", l.Name())
+	}
+
+	filter, groupBy, bucket, err := parseLogsAggregationQuery(urlPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return aggregator.AggregateLogs(*filter, groupBy, bucket)
+}
+
+// parseLogsAggregationQuery parses the filter, the group by fields and the
+// bucket size from the URL.
+func parseLogsAggregationQuery(fullUrl string) (*LogFilter, []string, time.Duration, error) {
+	filter, err := parseLogsQuery(fullUrl)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	parsedURL, err := url.Parse(fullUrl)
+	if err != nil {
+		return nil, nil, 0, errors.New("failed to parse URL")
+	}
+	params := parsedURL.Query()
+
+	var groupBy []string
+	for _, v := range params["group_by"] {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field != "" && !slices.Contains(groupBy, field) {
+				groupBy = append(groupBy, field)
+			}
+		}
+	}
+
+	var bucket time.Duration
+	if v := params.Get("bucket"); v != "" {
+		if bucket, err = time.ParseDuration(v); err != nil {
+			return nil, nil, 0, errors.New("invalid 'bucket' value")
+		}
+	}
+
+	if err := validateAggregation(groupBy, bucket); err != nil {
+		return nil, nil, 0, err
+	}
+
+	return filter, groupBy, bucket, nil
+}
@@ -0,0 +1,160 @@
+package syro
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// LogCaller is the location in the code which created the log.
+type LogCaller struct {
+	File     string `json:"file" bson:"file"`
+	Line     int    `json:"line" bson:"line"`
+	Function string `json:"function" bson:"function"`
+}
+
+// String returns the file (with its directory) and the line of the caller,
+// e.g. "api/users.go:42".
+func (c *LogCaller) String() string {
+	if c == nil {
+		return ""
+	}
+
+	file := c.File
+	if dir := filepath.Base(filepath.Dir(file)); dir != "." && dir != "/" {
+		file = dir + "/" + filepath.Base(file)
+	}
+
+	return file + ":" + strconv.Itoa(c.Line)
+}
+
+// syroPackage is the import path of the package, used to skip the frames of
+// the loggers when capturing the caller.
+var syroPackage = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	return funcPackage(runtime.FuncForPC(pc).Name())
+}()
+
+// loggerHelperFuncs are the functions of the package which call the methods
+// of the loggers, so they are skipped as well.
+var loggerHelperFuncs = map[string]bool{
+	"newLog":        true,
+	"logWithLevel":  true,
+	"recordLog":     true,
+	"writeLogs":     true,
+	"captureCaller": true,
+	"captureStack":  true,
+}
+
+// funcPackage returns the import path of the package of the function name,
+// e.g. "github.com/tompston/syro" for "github.com/tompston/syro.(*MongoLogger).Error".
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+
+	return name
+}
+
+// isLoggerFrame reports if the function is one of the methods of the loggers
+// of this package (or syrotest), one of the helpers which call them or a
+// function of log/slog, whose records are written with the SlogHandler.
+func isLoggerFrame(function string) bool {
+	pkg := funcPackage(function)
+	if pkg == "log/slog" {
+		return true
+	}
+
+	if pkg != syroPackage && pkg != syroPackage+"/syrotest" {
+		return false
+	}
+
+	name := strings.TrimPrefix(function, pkg+".")
+	if strings.HasPrefix(name, "(*") {
+		if end := strings.Index(name, ")"); end > 0 {
+			receiver := name[2:end]
+			return strings.HasSuffix(receiver, "Logger") || receiver == "SlogHandler"
+		}
+	}
+
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i] // closures, e.g. "newLog.func1"
+	}
+
+	return loggerHelperFuncs[name]
+}
+
+// maxStackDepth is the max number of frames of the captured stack traces.
+const maxStackDepth = 64
+
+// callerFrames returns the frames of the stack, starting from the code
+// which created the log. The frames of the loggers are skipped. The buffer
+// of the program counters is grown until the stack fits in it or enough
+// frames are found, so that the deep chains of the wrapped loggers do not
+// fill it with the logger frames.
+func callerFrames(depth int) []runtime.Frame {
+	for size := depth + 16; ; size *= 2 {
+		pcs := make([]uintptr, size)
+		n := runtime.Callers(3, pcs)
+
+		frames := collectFrames(pcs[:n], depth)
+		if len(frames) == depth || n < size {
+			return frames
+		}
+	}
+}
+
+// collectFrames returns up to depth frames of the program counters, without
+// the logger frames at the top of the stack.
+func collectFrames(pcs []uintptr, depth int) []runtime.Frame {
+	if len(pcs) == 0 {
+		return nil
+	}
+
+	frames := runtime.CallersFrames(pcs)
+
+	var result []runtime.Frame
+	skipping := true
+	for len(result) < depth {
+		frame, more := frames.Next()
+		if skipping && isLoggerFrame(frame.Function) {
+			if !more {
+				break
+			}
+			continue
+		}
+
+		skipping = false
+		result = append(result, frame)
+		if !more {
+			break
+		}
+	}
+
+	return result
+}
+
+// captureCaller returns the first frame of the stack which is not one of the
+// logger frames, so that the caller is the code which created the log.
+func captureCaller() *LogCaller {
+	frames := callerFrames(1)
+	if len(frames) == 0 {
+		return nil
+	}
+
+	return &LogCaller{File: frames[0].File, Line: frames[0].Line, Function: frames[0].Function}
+}
+
+// captureStack returns the stack trace of the code which created the log,
+// in the same format as the stack traces of the panics.
+func captureStack() string {
+	var b strings.Builder
+	for _, frame := range callerFrames(maxStackDepth) {
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+
+	return b.String()
+}
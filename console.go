@@ -0,0 +1,288 @@
+package syro
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConsoleFormat is the format of the logs which are printed to the console.
+type ConsoleFormat string
+
+const (
+	ConsoleText   ConsoleFormat = "text"   // aligned columns with the sorted fields (default)
+	ConsoleLogfmt ConsoleFormat = "logfmt" // key=value pairs, e.g. for log collectors
+	ConsoleJSON   ConsoleFormat = "json"   // one JSON object per line
+	ConsoleColor  ConsoleFormat = "color"  // text format with colors, for terminals
+	ConsoleAuto   ConsoleFormat = "auto"   // color if stdout is a terminal, text otherwise
+)
+
+// consoleColumnWidth is the min width of the source and event columns of the
+// text format.
+const consoleColumnWidth = 12
+
+const (
+	colorReset = "\033[0m"
+	colorDim   = "\033[2m"
+	colorBold  = "\033[1m"
+)
+
+var levelColors = map[LogLevel]string{
+	TRACE: "\033[90m",
+	DEBUG: "\033[36m",
+	INFO:  "\033[32m",
+	WARN:  "\033[33m",
+	ERROR: "\033[31m",
+	FATAL: "\033[1;35m",
+}
+
+// stdoutIsTerminal reports if the stdout is a terminal which supports colors.
+// The NO_COLOR env variable disables the colors (see https://no-color.org).
+var stdoutIsTerminal = sync.OnceValue(func() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+})
+
+// consoleFormat returns the format of the console output, with the auto
+// format resolved.
+func (s *LoggerSettings) consoleFormat() ConsoleFormat {
+	format := s.Format
+	if format == ConsoleAuto {
+		if stdoutIsTerminal() {
+			return ConsoleColor
+		}
+		return ConsoleText
+	}
+
+	if format == "" {
+		return ConsoleText
+	}
+
+	return format
+}
+
+// formatText formats the log as columns. If color is true, the level,
+// the timestamp and the keys of the fields are colored.
+func (log Log) formatText(settings *LoggerSettings, color bool) string {
+	paint := func(color string, v string) string { return v }
+	if color {
+		paint = func(c string, v string) string { return c + v + colorReset }
+	}
+
+	timeformat := settings.TimeFormat
+	if timeformat == "" {
+		timeformat = defaultTimeFormat
+	}
+
+	var b strings.Builder
+
+	b.WriteString(paint(colorDim, log.Timestamp.In(settings.location()).Format(timeformat)))
+	b.WriteString("  ")
+	b.WriteString(paint(levelColors[log.Level], fmt.Sprintf("%-6s", log.Level.String())))
+	b.WriteString("  ")
+	b.WriteString(padColumn(log.Source))
+	b.WriteString(padColumn(log.Event))
+	b.WriteString("  ")
+
+	if settings.ShowEventID && log.EventID != "" {
+		b.WriteString(paint(colorDim, "["+log.EventID+"]"))
+		b.WriteString(" ")
+	}
+
+	msg := escapeControl(log.Message)
+	if color && log.Level >= ERROR {
+		msg = paint(colorBold, msg)
+	}
+	b.WriteString(msg)
+
+	if len(log.Fields) > 0 {
+		b.WriteString("  ")
+
+		for _, k := range sortedKeys(log.Fields) {
+			b.WriteString(" ")
+			b.WriteString(paint(colorDim, k+"="))
+			b.WriteString(formatValue(log.Fields[k]))
+		}
+	}
+
+	if settings.ShowCaller && log.Caller != nil {
+		b.WriteString("  ")
+		b.WriteString(paint(colorDim, log.Caller.String()))
+	}
+
+	b.WriteString("\n")
+	return b.String()
+}
+
+// formatLogfmt formats the log as logfmt, with the fields after the
+// properties of the log.
+func (log Log) formatLogfmt(settings *LoggerSettings) string {
+	var b strings.Builder
+
+	pair := func(k string, v string) {
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(quoteValue(v))
+	}
+
+	pair("time", log.Timestamp.In(settings.location()).Format(time.RFC3339Nano))
+	pair("level", log.Level.String())
+
+	if log.Source != "" {
+		pair("source", log.Source)
+	}
+
+	if log.Event != "" {
+		pair("event", log.Event)
+	}
+
+	if settings.ShowEventID && log.EventID != "" {
+		pair("event_id", log.EventID)
+	}
+
+	pair("msg", log.Message)
+
+	if settings.ShowCaller && log.Caller != nil {
+		pair("caller", log.Caller.String())
+	}
+
+	for _, k := range sortedKeys(log.Fields) {
+		pair(k, fieldString(log.Fields[k]))
+	}
+
+	b.WriteString("\n")
+	return b.String()
+}
+
+// consoleJSONLog is the log printed with the JSON format. The keys of the
+// fields are sorted by encoding/json.
+type consoleJSONLog struct {
+	Time    string     `json:"time"`
+	Level   string     `json:"level"`
+	Source  string     `json:"source,omitempty"`
+	Event   string     `json:"event,omitempty"`
+	EventID string     `json:"event_id,omitempty"`
+	Message string     `json:"message"`
+	Caller  *LogCaller `json:"caller,omitempty"`
+	Fields  LogFields  `json:"fields,omitempty"`
+}
+
+// formatJSON formats the log as a single line JSON object.
+func (log Log) formatJSON(settings *LoggerSettings) string {
+	v := consoleJSONLog{
+		Time:    log.Timestamp.In(settings.location()).Format(time.RFC3339Nano),
+		Level:   log.Level.String(),
+		Source:  log.Source,
+		Event:   log.Event,
+		Message: log.Message,
+		Fields:  log.Fields,
+	}
+
+	if settings.ShowEventID {
+		v.EventID = log.EventID
+	}
+
+	if settings.ShowCaller {
+		v.Caller = log.Caller
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		// fields which cannot be encoded (e.g. channels) are printed as strings
+		v.Fields = make(LogFields, len(log.Fields))
+		for k, val := range log.Fields {
+			v.Fields[k] = fieldString(val)
+		}
+
+		data, _ = json.Marshal(v)
+	}
+
+	return string(data) + "\n"
+}
+
+// location returns the location of the timestamps, UTC by default.
+func (s *LoggerSettings) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+
+	return s.Location
+}
+
+// padColumn pads the value to the width of the column. Longer values are
+// followed by a single space, so that the columns do not merge.
+func padColumn(v string) string {
+	if len(v) >= consoleColumnWidth {
+		return v + " "
+	}
+
+	return fmt.Sprintf("%-*s", consoleColumnWidth, v)
+}
+
+func sortedKeys(fields LogFields) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// fieldString returns the string of the value. Maps are printed with the
+// sorted keys by fmt.
+func fieldString(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case error:
+		return val.Error()
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// formatValue returns the string of the value which is quoted if needed.
+func formatValue(v any) string { return quoteValue(fieldString(v)) }
+
+// quoteValue quotes the value if it is empty or contains spaces, quotes,
+// equal signs or control characters, so that the key=value pairs can be
+// parsed.
+func quoteValue(v string) string {
+	if v == "" {
+		return `""`
+	}
+
+	if strings.ContainsFunc(v, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '=' || r == '\\' || r == 0x7f || !strconv.IsPrint(r)
+	}) {
+		return strconv.Quote(v)
+	}
+
+	return v
+}
+
+// escapeControl escapes the newlines and the other control characters of the
+// message, so that every log is printed on a single line.
+func escapeControl(v string) string {
+	if !strings.ContainsFunc(v, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return v
+	}
+
+	quoted := strconv.Quote(v)
+	return strings.ReplaceAll(quoted[1:len(quoted)-1], `\"`, `"`)
+}
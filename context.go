@@ -0,0 +1,70 @@
+package syro
+
+import "context"
+
+type (
+	loggerKey  struct{}
+	eventIDKey struct{}
+	traceIDKey struct{}
+)
+
+// ContextWithLogger returns a copy of the context which stores the logger.
+func ContextWithLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger stored in the context. If the context has
+// an event ID (or a trace ID, if the event ID is not set), the returned
+// logger uses it as the EventID of the logs. If both of them are set, the
+// trace ID is added to the fields of the logs.
+//
+// If there is no logger in the context, a ConsoleLogger with the default
+// settings is returned, so the result is never nil.
+func FromContext(ctx context.Context) Logger {
+	if ctx == nil {
+		return NewConsoleLogger(nil)
+	}
+
+	logger, ok := ctx.Value(loggerKey{}).(Logger)
+	if !ok || logger == nil {
+		logger = NewConsoleLogger(nil)
+	}
+
+	eventID := EventIDFromContext(ctx)
+	traceID := TraceIDFromContext(ctx)
+
+	switch {
+	case eventID != "" && traceID != "" && eventID != traceID:
+		return logger.WithEventID(eventID).With(LogFields{"trace_id": traceID})
+	case eventID != "":
+		return logger.WithEventID(eventID)
+	case traceID != "":
+		return logger.WithEventID(traceID)
+	default:
+		return logger
+	}
+}
+
+// ContextWithEventID returns a copy of the context which stores the event ID
+// used by the logger returned from FromContext.
+func ContextWithEventID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, eventIDKey{}, id)
+}
+
+// EventIDFromContext returns the event ID stored in the context.
+func EventIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(eventIDKey{}).(string)
+	return id
+}
+
+// ContextWithTraceID returns a copy of the context which stores the trace ID
+// (e.g. the ID of the request which is shared between the services).
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceIDFromContext returns the trace ID stored in the context.
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
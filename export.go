@@ -0,0 +1,226 @@
+package syro
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExportFormat is the format of the exported documents.
+type ExportFormat string
+
+const (
+	ExportNDJSON ExportFormat = "ndjson" // one JSON document per line
+	ExportCSV    ExportFormat = "csv"    // header with the columns and one row per document
+)
+
+// exportBatchSize is the number of documents which are requested with a
+// single page while exporting.
+const exportBatchSize = 1_000
+
+var (
+	logExportColumns  = []string{"timestamp", "level", "source", "event", "event_id", "message"}
+	execExportColumns = []string{"_id", "source", "name", "run_id", "status", "initialized_at", "finished_at", "execution_time", "error"}
+)
+
+// ExportLogs writes the logs which match the filter to the writer, from the
+// newest to the oldest. The logs are requested in pages with the cursor
+// if the logger implements the LogPager interface, so that all of the
+// logs are not loaded at once. The pagination of the filter is ignored.
+//
+// For the CSV format, the columns can be selected with the names of the
+// Log json fields and the fields of the log with "fields.<key>" (nested
+// keys are separated with a dot). If the columns are not specified, all
+// of the standard columns and the flattened fields are used.
+func ExportLogs(ctx context.Context, logger Logger, filter LogFilter, format ExportFormat, w io.Writer, columns ...string) error {
+	if logger == nil {
+		return fmt.Errorf("logger is nil")
+	}
+
+	fetch := func(cursor string) (*Page[Log], error) {
+		f := filter
+		f.TimeseriesFilter = TimeseriesFilter{From: filter.From, To: filter.To, Limit: exportBatchSize, Cursor: cursor}
+
+		if _, ok := logger.(LogPager); ok {
+			return findLogsPage(logger, f, exportBatchSize)
+		}
+
+		// all of the logs are returned with a single page
+		f.Limit = 0
+		return findLogsPage(logger, f, 0)
+	}
+
+	return exportPages(ctx, fetch, format, w, columns, logExportColumns, logExportRow)
+}
+
+// ExportExecutions writes the executions which match the filter to the
+// writer, in the same way as ExportLogs. The CSV columns can be selected
+// with the names of the CronExecLog json fields. The execution_time
+// column is in milliseconds.
+func ExportExecutions(ctx context.Context, storage CronStorage, filter CronExecFilter, format ExportFormat, w io.Writer, columns ...string) error {
+	if storage == nil {
+		return fmt.Errorf("storage is nil")
+	}
+
+	fetch := func(cursor string) (*Page[CronExecLog], error) {
+		f := filter
+		f.TimeseriesFilter = TimeseriesFilter{From: filter.From, To: filter.To, Limit: exportBatchSize, Cursor: cursor}
+		return storage.FindExecutionsPage(f, exportBatchSize)
+	}
+
+	return exportPages(ctx, fetch, format, w, columns, execExportColumns, execExportRow)
+}
+
+// exportPages writes all of the pages which are returned by fetch. If the CSV
+// columns are not specified, the pages are read twice, first to find all of
+// the columns of the rows and then to write them.
+func exportPages[T any](
+	ctx context.Context,
+	fetch func(cursor string) (*Page[T], error),
+	format ExportFormat,
+	w io.Writer,
+	columns []string,
+	defaultColumns []string,
+	row func(T) map[string]string,
+) error {
+	switch format {
+	case ExportNDJSON:
+		enc := json.NewEncoder(w)
+		return forEachPage(ctx, fetch, func(item T) error { return enc.Encode(item) })
+	case ExportCSV:
+	default:
+		return fmt.Errorf("invalid export format: %v", format)
+	}
+
+	if len(columns) == 0 {
+		extra := make(map[string]bool)
+		err := forEachPage(ctx, fetch, func(item T) error {
+			// only the flattened columns are added to the default ones
+			for col := range row(item) {
+				if strings.Contains(col, ".") {
+					extra[col] = true
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		columns = append([]string{}, defaultColumns...)
+		extraColumns := make([]string, 0, len(extra))
+		for col := range extra {
+			extraColumns = append(extraColumns, col)
+		}
+		sort.Strings(extraColumns)
+		columns = append(columns, extraColumns...)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	err := forEachPage(ctx, fetch, func(item T) error {
+		values := row(item)
+		for i, col := range columns {
+			record[i] = values[col]
+		}
+		return cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// forEachPage calls fn for every item of the pages, until there is no next cursor.
+func forEachPage[T any](ctx context.Context, fetch func(cursor string) (*Page[T], error), fn func(T) error) error {
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page, err := fetch(cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range page.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func logExportRow(log Log) map[string]string {
+	row := map[string]string{
+		"timestamp": log.Timestamp.UTC().Format(time.RFC3339Nano),
+		"_id":       log.ID,
+		"level":     log.Level.String(),
+		"source":    log.Source,
+		"event":     log.Event,
+		"event_id":  log.EventID,
+		"message":   log.Message,
+		"caller":    log.Caller.String(),
+		"stack":     log.Stack,
+	}
+
+	flattenFields(row, "fields", log.Fields)
+	return row
+}
+
+func execExportRow(ex CronExecLog) map[string]string {
+	return map[string]string{
+		"_id":            ex.ID,
+		"source":         ex.Source,
+		"name":           ex.Name,
+		"run_id":         ex.RunID,
+		"status":         string(ex.Status),
+		"initialized_at": ex.InitializedAt.UTC().Format(time.RFC3339Nano),
+		"finished_at":    ex.FinishedAt.UTC().Format(time.RFC3339Nano),
+		"execution_time": strconv.FormatInt(ex.ExecutionTime.Milliseconds(), 10),
+		"error":          ex.Error,
+	}
+}
+
+// flattenFields adds the fields to the row, with the keys of the nested
+// fields separated with a dot.
+func flattenFields(row map[string]string, prefix string, fields map[string]any) {
+	for k, v := range fields {
+		key := prefix + "." + k
+
+		switch val := v.(type) {
+		case LogFields:
+			flattenFields(row, key, val)
+		case map[string]any:
+			flattenFields(row, key, val)
+		case primitive.D: // nested documents of the logs from mongo
+			flattenFields(row, key, val.Map())
+		case time.Time:
+			row[key] = val.UTC().Format(time.RFC3339Nano)
+		case nil:
+			row[key] = ""
+		default:
+			row[key] = fmt.Sprint(val)
+		}
+	}
+}
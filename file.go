@@ -0,0 +1,508 @@
+package syro
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileLogger is a Logger which writes the logs as newline delimited JSON to
+// the files in a directory. The file is rotated when it reaches the max
+// size or when the day changes. Rotated files are compressed with gzip.
+//
+// The active file is named <prefix>-<start>.jsonl and the rotated files are
+// named <prefix>-<start>_<end>.jsonl.gz, so that FindLogs only has to read
+// the files which overlap with the time range of the filter.
+type FileLogger struct {
+	Settings *LoggerSettings
+	Source   string
+	Event    string
+	EventID  string
+	Fields   LogFields   // Default fields which are added to every log
+	w        *fileWriter // shared by the child loggers
+}
+
+var _ Logger = (*FileLogger)(nil)
+
+// FileLoggerOptions are the settings of the files of the FileLogger.
+type FileLoggerOptions struct {
+	Dir      string // Directory of the log files
+	Prefix   string // Optional. Prefix of the file names, defaults to "syro"
+	MaxSize  int64  // Optional. Max size of the active file in bytes, defaults to 100MB
+	MaxFiles int    // Optional. Number of rotated files which are kept, 0 means all of them
+}
+
+const (
+	fileTimeFormat   = "20060102T150405.000000000"
+	fileExt          = ".jsonl"
+	fileRotatedExt   = ".jsonl.gz"
+	defaultFileLimit = 100 << 20
+)
+
+// NewFileLogger creates the directory if it does not exist. Active files
+// which are left from the previous run are rotated.
+func NewFileLogger(opts FileLoggerOptions, settings *LoggerSettings) (*FileLogger, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("dir has to be specified")
+	}
+
+	if opts.Prefix == "" {
+		opts.Prefix = "syro"
+	}
+
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultFileLimit
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	w := &fileWriter{opts: opts}
+
+	// rotate the active files of the previous run
+	files, err := w.files()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if !f.rotated {
+			end := f.start
+			if info, err := os.Stat(f.path); err == nil {
+				end = info.ModTime().UTC()
+			}
+
+			if err := w.compress(f.path, f.start, end); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := w.prune(); err != nil {
+		return nil, err
+	}
+
+	return &FileLogger{Settings: settings, w: w}, nil
+}
+
+// Close closes the active file.
+func (lg *FileLogger) Close() error { return lg.w.close() }
+
+func (lg *FileLogger) GetProps() LoggerProps {
+	return LoggerProps{
+		Settings: lg.Settings,
+		Source:   lg.Source,
+		Event:    lg.Event,
+		EventID:  lg.EventID,
+		Fields:   lg.Fields,
+	}
+}
+
+func (lg *FileLogger) Name() string { return "file" }
+
+// GetTableName returns the directory of the log files.
+func (lg *FileLogger) GetTableName() string { return lg.w.opts.Dir }
+
+func (lg *FileLogger) log(level LogLevel, msg string, lf ...LogFields) error {
+	_, _, err := lg.record(level, msg, lf...)
+	return err
+}
+
+func (lg *FileLogger) record(level LogLevel, msg string, lf ...LogFields) (Log, bool, error) {
+	if !lg.Settings.Enabled(level, lg.Source) {
+		return Log{}, false, nil
+	}
+
+	log := newLog(lg.Settings, level, msg, lg.Source, lg.Event, lg.EventID, mergeFields(lg.Fields, lf...))
+	log.ID = newRandomID()
+
+	err := lg.w.write(log)
+
+	if lg.Settings == nil || !lg.Settings.DisableConsole {
+		fmt.Print(log.String(lg))
+	}
+
+	return log, true, err
+}
+
+func (lg *FileLogger) clone() *FileLogger {
+	c := *lg
+	return &c
+}
+
+func (lg *FileLogger) WithSource(v string) Logger {
+	c := lg.clone()
+	c.Source = v
+	return c
+}
+
+func (lg *FileLogger) WithEvent(v string) Logger {
+	c := lg.clone()
+	c.Event = v
+	return c
+}
+
+func (lg *FileLogger) WithEventID(v string) Logger {
+	c := lg.clone()
+	c.EventID = v
+	return c
+}
+
+func (lg *FileLogger) With(fields LogFields) Logger {
+	c := lg.clone()
+	c.Fields = mergeFields(lg.Fields, fields)
+	return c
+}
+
+func (lg *FileLogger) Debug(msg string, lf ...LogFields) error { return lg.log(DEBUG, msg, lf...) }
+func (lg *FileLogger) Trace(msg string, lf ...LogFields) error { return lg.log(TRACE, msg, lf...) }
+func (lg *FileLogger) Error(msg string, lf ...LogFields) error { return lg.log(ERROR, msg, lf...) }
+func (lg *FileLogger) Info(msg string, lf ...LogFields) error  { return lg.log(INFO, msg, lf...) }
+func (lg *FileLogger) Warn(msg string, lf ...LogFields) error  { return lg.log(WARN, msg, lf...) }
+func (lg *FileLogger) Fatal(msg string, lf ...LogFields) error { return lg.log(FATAL, msg, lf...) }
+
+// WriteLogs writes the logs to the active file. The logs are not printed to
+// the console.
+func (lg *FileLogger) WriteLogs(logs []Log) error {
+	for _, log := range enabledLogs(lg.Settings, logs) {
+		if log.ID == "" {
+			log.ID = newRandomID()
+		}
+
+		if err := lg.w.write(log); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LogExists checks if a log which matches the filter exists. The filter
+// must have a LogFilter type.
+func (lg *FileLogger) LogExists(filter any) (bool, error) {
+	f, ok := filter.(LogFilter)
+	if !ok {
+		return false, errors.New("filter must have a LogFilter type")
+	}
+
+	f.Limit, f.Skip = 1, 0
+	logs, err := lg.FindLogs(f, 1)
+	return len(logs) > 0, err
+}
+
+// FindLogs reads the files which overlap with the time range of the filter
+// and returns the logs that match it.
+func (lg *FileLogger) FindLogs(filter LogFilter, maxLimit int64) ([]Log, error) {
+	page, err := lg.FindLogsPage(filter, maxLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+// FindLogsPage returns a page of the logs which match the filter.
+func (lg *FileLogger) FindLogsPage(filter LogFilter, maxLimit int64) (*Page[Log], error) {
+	matches, err := filter.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := lg.w.files()
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]Log, 0)
+	for _, f := range files {
+		if !f.overlaps(filter.From, filter.To) {
+			continue
+		}
+
+		err := readLogFile(f.path, f.rotated, func(log Log) {
+			if matches(&log) {
+				logs = append(logs, log)
+			}
+		})
+
+		// the file could be rotated while it was being read
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return pageLogs(logs, filter.TimeseriesFilter, maxLimit)
+}
+
+// readLogFile decodes every line of the file as a Log. Lines which can not
+// be decoded (e.g. a partially written line) are skipped.
+func readLogFile(path string, compressed bool, fn func(Log)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var log Log
+			if json.Unmarshal(line, &log) == nil {
+				fn(log)
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// fileWriter writes the logs to the active file and rotates it. The logs
+// are expected to be written in the order of their timestamps.
+type fileWriter struct {
+	opts  FileLoggerOptions
+	mu    sync.Mutex
+	file  *os.File
+	size  int64
+	start time.Time
+}
+
+// logFile is a log file in the directory, with the time bounds parsed from the name.
+type logFile struct {
+	path    string
+	start   time.Time
+	end     time.Time // zero for the active file
+	rotated bool
+}
+
+// overlaps checks if the file can contain logs between from and to. Zero
+// values mean that the range is open.
+func (f logFile) overlaps(from, to time.Time) bool {
+	if !to.IsZero() && f.start.After(to) {
+		return false
+	}
+
+	if !from.IsZero() && !f.end.IsZero() && f.end.Before(from) {
+		return false
+	}
+
+	return true
+}
+
+func (w *fileWriter) write(log Log) error {
+	data, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// the time of the log is used for the bounds of the files, so that the
+	// logs are always within the bounds of the file they are written to
+	now := log.Timestamp.UTC()
+
+	if w.file != nil && (w.size+int64(len(data)) > w.opts.MaxSize || !sameDay(w.start, now)) {
+		if err := w.rotate(now); err != nil {
+			return err
+		}
+	}
+
+	if w.file == nil {
+		path := filepath.Join(w.opts.Dir, w.opts.Prefix+"-"+now.Format(fileTimeFormat)+fileExt)
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+
+		w.file, w.size, w.start = file, 0, now
+	}
+
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+	return err
+}
+
+// rotate closes the active file, compresses it and removes the old files.
+func (w *fileWriter) rotate(now time.Time) error {
+	path := w.file.Name()
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	if err := w.compress(path, w.start, now); err != nil {
+		return err
+	}
+
+	return w.prune()
+}
+
+// compress writes the gzip version of the file with the time bounds in the
+// name and removes the original file. The archive is written to a temp file
+// which is renamed when it is complete, so that the readers never open a
+// partially written archive.
+func (w *fileWriter) compress(path string, start, end time.Time) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	name := filepath.Join(w.opts.Dir, w.opts.Prefix+"-"+start.Format(fileTimeFormat)+"_"+end.Format(fileTimeFormat)+fileRotatedExt)
+	tmp := name + ".tmp" // not matched by the files of the logger
+
+	if err := writeGzip(tmp, src); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// writeGzip writes the compressed content of the reader to the file.
+func writeGzip(path string, r io.Reader) error {
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, r); err != nil {
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	return dst.Close()
+}
+
+// prune removes the oldest rotated files if there are more than MaxFiles.
+func (w *fileWriter) prune() error {
+	if w.opts.MaxFiles <= 0 {
+		return nil
+	}
+
+	files, err := w.files()
+	if err != nil {
+		return err
+	}
+
+	var rotated []logFile
+	for _, f := range files {
+		if f.rotated {
+			rotated = append(rotated, f)
+		}
+	}
+
+	for i := 0; i < len(rotated)-w.opts.MaxFiles; i++ {
+		if err := os.Remove(rotated[i].path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *fileWriter) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// files returns the log files in the directory, sorted by the start time.
+func (w *fileWriter) files() ([]logFile, error) {
+	entries, err := os.ReadDir(w.opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := w.opts.Prefix + "-"
+	files := make([]logFile, 0, len(entries))
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		f := logFile{path: filepath.Join(w.opts.Dir, name)}
+		bounds := strings.TrimPrefix(name, prefix)
+
+		switch {
+		case strings.HasSuffix(name, fileRotatedExt):
+			f.rotated = true
+			start, end, ok := strings.Cut(strings.TrimSuffix(bounds, fileRotatedExt), "_")
+			if !ok {
+				continue
+			}
+
+			if f.start, err = time.Parse(fileTimeFormat, start); err != nil {
+				continue
+			}
+
+			if f.end, err = time.Parse(fileTimeFormat, end); err != nil {
+				continue
+			}
+		case strings.HasSuffix(name, fileExt):
+			if f.start, err = time.Parse(fileTimeFormat, strings.TrimSuffix(bounds, fileExt)); err != nil {
+				continue
+			}
+		default:
+			continue
+		}
+
+		files = append(files, f)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].start.Before(files[j].start) })
+	return files, nil
+}
+
+func sameDay(a, b time.Time) bool {
+	y1, m1, d1 := a.Date()
+	y2, m2, d2 := b.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
@@ -1,18 +1,112 @@
 package syro
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/url"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type TimeseriesFilter struct {
-	From  time.Time
-	To    time.Time
-	Limit int64
-	Skip  int64
+	From   time.Time
+	To     time.Time
+	Limit  int64
+	Skip   int64
+	Cursor string // Optional. NextCursor of the previous page, used instead of the Skip
+	Total  bool   // Optional. Count the total number of the documents which match the filter
+}
+
+// Page is a page of the results, sorted by time in descending order.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"` // Empty if there are no more results
+	Total      *int64 `json:"total,omitempty"`       // Set if the Total of the filter is true
+}
+
+// pageCursor is the position of the last document of the page. The
+// documents are sorted by the time and the id in descending order, so
+// the next page starts after this position.
+type pageCursor struct {
+	Time time.Time
+	ID   string
+}
+
+func encodeCursor(t time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(t.UnixNano(), 10) + "_" + id))
+}
+
+func decodeCursor(v string) (pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+
+	ts, id, ok := strings.Cut(string(data), "_")
+	if !ok {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+
+	return pageCursor{Time: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// before checks if the document with the time and id comes after the
+// cursor in the descending order.
+func (c pageCursor) before(t time.Time, id string) bool {
+	return t.Before(c.Time) || (t.Equal(c.Time) && id < c.ID)
+}
+
+// LogPager is implemented by the loggers which support the cursor
+// pagination of the logs.
+type LogPager interface {
+	FindLogsPage(filter LogFilter, maxLimit int64) (*Page[Log], error)
+}
+
+// RequestLogsPage returns a page of the logs which match the URL params. If
+// the logger does not implement the LogPager interface, the logs are
+// returned without the cursor and the total.
+func RequestLogsPage(l Logger, maxLimit int64, urlPath string) (*Page[Log], error) {
+	if l == nil {
+		return nil, errors.New("logger is nil")
+	}
+
+	filter, err := parseLogsQuery(urlPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return findLogsPage(l, *filter, maxLimit)
+}
+
+func findLogsPage(l Logger, filter LogFilter, maxLimit int64) (*Page[Log], error) {
+	if pager, ok := l.(LogPager); ok {
+		return pager.FindLogsPage(filter, maxLimit)
+	}
+
+	if filter.Cursor != "" {
+		return nil, fmt.Errorf("%v logger does not support cursors", l.Name())
+	}
+
+	logs, err := l.FindLogs(filter, maxLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	if logs == nil {
+		logs = []Log{}
+	}
+
+	return &Page[Log]{Items: logs}, nil
 }
 
 func RequestLogs(l Logger, maxLimit int64, urlPath string) ([]Log, error) {
@@ -62,6 +156,23 @@ func parseUrlToTimeseriesParams(vals url.Values) (*TimeseriesFilter, error) {
 		filter.Limit = parsedLimit
 	}
 
+	if cursor := vals.Get("cursor"); cursor != "" {
+		if _, err := decodeCursor(cursor); err != nil {
+			return nil, errors.New("invalid 'cursor' value")
+		}
+
+		filter.Cursor = cursor
+	}
+
+	if total := vals.Get("total"); total != "" {
+		parsedTotal, err := strconv.ParseBool(total)
+		if err != nil {
+			return nil, errors.New("invalid 'total' value")
+		}
+
+		filter.Total = parsedTotal
+	}
+
 	// Parse "skip"
 	if skip := vals.Get("skip"); skip != "" {
 		parsedSkip, err := strconv.ParseInt(skip, 10, 64)
@@ -93,13 +204,59 @@ func parseLogsQuery(fullUrl string) (*LogFilter, error) {
 	}
 
 	filter.TimeseriesFilter = *ts
-	filter.Source = params.Get("source")
-	filter.Event = params.Get("event")
 	filter.EventID = params.Get("event_id")
 
-	if parsedLevel, err := strconv.Atoi(params.Get("level")); err == nil {
-		logLevel := LogLevel(parsedLevel)
-		filter.Level = &logLevel
+	// repeated values match any of them
+	if sources := params["source"]; len(sources) == 1 {
+		filter.Source = sources[0]
+	} else {
+		filter.Sources = sources
+	}
+
+	if events := params["event"]; len(events) == 1 {
+		filter.Event = events[0]
+	} else {
+		filter.Events = events
+	}
+
+	for key, level := range map[string]**LogLevel{
+		"level":     &filter.Level,
+		"min_level": &filter.MinLevel,
+		"max_level": &filter.MaxLevel,
+	} {
+		if v := params.Get(key); v != "" {
+			parsed, err := ParseLogLevel(v)
+
+			// invalid values of the level are ignored, as they always were
+			if err != nil && key == "level" {
+				continue
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid '%v' value: %v", key, v)
+			}
+
+			*level = &parsed
+		}
+	}
+
+	filter.Message = params.Get("message")
+	filter.MessageRegex = params.Get("message_regex")
+	filter.Caller = params.Get("caller")
+
+	// fields.<key>=value
+	for key, vals := range params {
+		if name, ok := strings.CutPrefix(key, "fields."); ok && name != "" && len(vals) > 0 {
+			if filter.Fields == nil {
+				filter.Fields = LogFields{}
+			}
+
+			filter.Fields[name] = vals[0]
+		}
+	}
+
+	if err := filter.validate(); err != nil {
+		return nil, err
 	}
 
 	return &filter, nil
@@ -115,6 +272,8 @@ type LogPayload struct {
 	Level   string    `json:"level"`
 }
 
+// ParseLogs converts the payloads to logs. The logs are redacted when they
+// are written by the logger, or they can be redacted with LoggerSettings.Redact.
 func ParseLogs(body []LogPayload) ([]Log, error) {
 	if len(body) == 0 {
 		return nil, errors.New("no logs to parse")
@@ -123,22 +282,9 @@ func ParseLogs(body []LogPayload) ([]Log, error) {
 	logs := make([]Log, len(body))
 
 	for i, b := range body {
-		level := LogLevel(0)
-		switch b.Level {
-		case "error":
-			level = ERROR
-		case "info":
-			level = INFO
-		case "debug":
-			level = DEBUG
-		case "warn":
-			level = WARN
-		case "trace":
-			level = TRACE
-		case "fatal":
-			level = FATAL
-		default:
-			return nil, fmt.Errorf("invalid log level: %s", b.Level)
+		level, err := ParseLogLevel(b.Level)
+		if err != nil {
+			return nil, err
 		}
 
 		logs[i] = Log{
@@ -154,3 +300,186 @@ func ParseLogs(body []LogPayload) ([]Log, error) {
 
 	return logs, nil
 }
+
+// sources returns the combined Source and Sources of the filter.
+func (f LogFilter) sources() []string { return combineFilterValues(f.Source, f.Sources) }
+
+// events returns the combined Event and Events of the filter.
+func (f LogFilter) events() []string { return combineFilterValues(f.Event, f.Events) }
+
+func combineFilterValues(v string, vals []string) []string {
+	combined := make([]string, 0, len(vals)+1)
+	if v != "" {
+		combined = append(combined, v)
+	}
+
+	for _, val := range vals {
+		if val != "" {
+			combined = append(combined, val)
+		}
+	}
+
+	return combined
+}
+
+// matcher validates the filter and returns a func which checks if the log
+// matches it, using the same rules as the query of the MongoLogger. Used
+// by the loggers which filter the logs in Go.
+func (f LogFilter) matcher() (func(log *Log) bool, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+
+	var re *regexp.Regexp
+	if f.MessageRegex != "" {
+		re = regexp.MustCompile(f.MessageRegex)
+	}
+
+	sources, events := f.sources(), f.events()
+	message := strings.ToLower(f.Message)
+
+	return func(log *Log) bool {
+		if !f.From.IsZero() && log.Timestamp.Before(f.From) {
+			return false
+		}
+
+		if !f.To.IsZero() && log.Timestamp.After(f.To) {
+			return false
+		}
+
+		if f.Level != nil && *f.Level >= TRACE && *f.Level <= FATAL && log.Level != *f.Level {
+			return false
+		}
+
+		if f.MinLevel != nil && log.Level < *f.MinLevel {
+			return false
+		}
+
+		if f.MaxLevel != nil && log.Level > *f.MaxLevel {
+			return false
+		}
+
+		if len(sources) > 0 && !slices.Contains(sources, log.Source) {
+			return false
+		}
+
+		if len(events) > 0 && !slices.Contains(events, log.Event) {
+			return false
+		}
+
+		if f.EventID != "" && log.EventID != f.EventID {
+			return false
+		}
+
+		if message != "" && !strings.Contains(strings.ToLower(log.Message), message) {
+			return false
+		}
+
+		if re != nil && !re.MatchString(log.Message) {
+			return false
+		}
+
+		if f.Caller != "" && (log.Caller == nil || !strings.Contains(log.Caller.File, f.Caller) && !strings.Contains(log.Caller.Function, f.Caller)) {
+			return false
+		}
+
+		for key, expected := range f.Fields {
+			v, ok := lookupField(log.Fields, key)
+			if !ok || fmt.Sprint(v) != fmt.Sprint(expected) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+// lookupField returns the value of the field. Keys of nested fields are
+// separated with a dot.
+func lookupField(fields map[string]any, key string) (any, bool) {
+	if v, ok := fields[key]; ok {
+		return v, true
+	}
+
+	head, rest, ok := strings.Cut(key, ".")
+	if !ok {
+		return nil, false
+	}
+
+	switch nested := fields[head].(type) {
+	case LogFields:
+		return lookupField(nested, rest)
+	case map[string]any:
+		return lookupField(nested, rest)
+	default:
+		return nil, false
+	}
+}
+
+// validate returns an error if the time range, the levels or the message
+// regex of the filter are invalid.
+func (f LogFilter) validate() error {
+	if !f.From.IsZero() && !f.To.IsZero() && f.From.After(f.To) {
+		return errors.New("'from' date cannot be after 'to' date")
+	}
+
+	if f.MinLevel != nil && f.MaxLevel != nil && *f.MinLevel > *f.MaxLevel {
+		return errors.New("'min_level' cannot be above 'max_level'")
+	}
+
+	if f.MessageRegex != "" {
+		if _, err := regexp.Compile(f.MessageRegex); err != nil {
+			return fmt.Errorf("invalid message regex: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// pageLogs sorts the logs by time in descending order and returns the
+// page after the cursor (or the skip) of the filter, in the same way as
+// the MongoLogger.
+func pageLogs(logs []Log, filter TimeseriesFilter, maxLimit int64) (*Page[Log], error) {
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].Timestamp.Equal(logs[j].Timestamp) {
+			return logs[i].ID > logs[j].ID
+		}
+
+		return logs[i].Timestamp.After(logs[j].Timestamp)
+	})
+
+	page := &Page[Log]{}
+	if filter.Total {
+		total := int64(len(logs))
+		page.Total = &total
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		start := sort.Search(len(logs), func(i int) bool { return cursor.before(logs[i].Timestamp, logs[i].ID) })
+		logs = logs[start:]
+	} else if filter.Skip >= int64(len(logs)) {
+		logs = logs[:0]
+	} else {
+		logs = logs[filter.Skip:]
+	}
+
+	userLimit := filter.Limit
+	if userLimit > maxLimit {
+		userLimit = maxLimit
+	}
+
+	// same as in mongo, a limit of 0 means no limit
+	if userLimit > 0 && int64(len(logs)) > userLimit {
+		logs = logs[:userLimit]
+		last := logs[len(logs)-1]
+		page.NextCursor = encodeCursor(last.Timestamp, last.ID)
+	}
+
+	page.Items = append([]Log{}, logs...)
+	return page, nil
+}
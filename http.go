@@ -0,0 +1,156 @@
+package syro
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPMiddlewareOptions are the settings of the request logging middleware.
+type HTTPMiddlewareOptions struct {
+	Source        string                       // Optional. Source of the logs (e.g. name of the service), defaults to the source of the logger
+	EventIDHeader string                       // Optional. Header with the id of the request, defaults to "X-Request-ID"
+	Event         func(r *http.Request) string // Optional. Event of the logs, defaults to the route pattern or the method
+	Level         func(status int) LogLevel    // Optional. Level of the request log, defaults to error for 5xx, warn for 4xx and info otherwise
+	Message       string                       // Optional. Message of the request log, defaults to "http request"
+}
+
+const maxEventIDLength = 128
+
+// HTTPMiddleware returns a middleware which logs every request. A child
+// logger with the source, event and the event id of the request is
+// stored in the context of the request, so the handlers can use it
+// with FromContext.
+//
+// The event id is taken from the EventIDHeader of the request or generated
+// if it is missing, and is returned in the same header of the response.
+//
+// The route pattern is only known if the middleware wraps the handler of
+// the route. If it wraps the whole ServeMux, the method is used as the
+// event, unless the Event option is specified.
+func HTTPMiddleware(logger Logger, opts *HTTPMiddlewareOptions) func(http.Handler) http.Handler {
+	o := HTTPMiddlewareOptions{}
+	if opts != nil {
+		o = *opts
+	}
+
+	if o.EventIDHeader == "" {
+		o.EventIDHeader = "X-Request-ID"
+	}
+
+	if o.Level == nil {
+		o.Level = httpStatusLevel
+	}
+
+	if o.Message == "" {
+		o.Message = "http request"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			eventID := r.Header.Get(o.EventIDHeader)
+			if !validEventID(eventID) {
+				eventID = newRandomID()
+			}
+			w.Header().Set(o.EventIDHeader, eventID)
+
+			reqLogger := logger
+			if o.Source != "" {
+				reqLogger = reqLogger.WithSource(o.Source)
+			}
+
+			event := r.Method
+			if o.Event != nil {
+				event = o.Event(r)
+			} else if r.Pattern != "" {
+				event = r.Pattern
+			}
+			reqLogger = reqLogger.WithEvent(event).WithEventID(eventID)
+
+			ctx := ContextWithLogger(r.Context(), reqLogger)
+			ctx = ContextWithEventID(ctx, eventID)
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			logWithLevel(reqLogger, o.Level(status), o.Message, LogFields{
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"status":      status,
+				"bytes":       rec.bytes,
+				"duration_ms": time.Since(start).Milliseconds(),
+				"remote_addr": r.RemoteAddr,
+			})
+		})
+	}
+}
+
+func httpStatusLevel(status int) LogLevel {
+	switch {
+	case status >= 500:
+		return ERROR
+	case status >= 400:
+		return WARN
+	default:
+		return INFO
+	}
+}
+
+// validEventID checks if the id from the header can be used as the event id.
+// Ids which are too long or have non printable characters are ignored,
+// so that the clients cannot write arbitrary data to the logs.
+func validEventID(id string) bool {
+	if id == "" || len(id) > maxEventIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// statusRecorder stores the status code and the number of bytes written to
+// the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Flush implements http.Flusher, so that streaming responses work with the middleware.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original ResponseWriter, used by http.ResponseController.
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
@@ -0,0 +1,185 @@
+package syro
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// LogsHandlerOptions are the settings of the LogsHandler.
+type LogsHandlerOptions struct {
+	MaxLimit    int64                                         // Optional. Max number of logs returned by a GET request, defaults to 100
+	MaxBodySize int64                                         // Optional. Max size of the body of a POST request in bytes, defaults to 1MB
+	MaxBatch    int                                           // Optional. Max number of logs in a POST request, defaults to 1000
+	Auth        func(r *http.Request, sources []string) error // Optional. Called before the logs are queried or stored, the request is rejected if it returns an error
+}
+
+const (
+	defaultLogsMaxLimit    = 100
+	defaultLogsMaxBodySize = 1 << 20
+	defaultLogsMaxBatch    = 1_000
+)
+
+// LogsHandler returns a handler for querying and ingesting the logs.
+//
+// GET requests return the page of the logs which match the URL params
+// (see RequestLogsPage) as JSON. POST requests accept a JSON array of
+// LogPayload, which are validated with ParseLogs and stored with a
+// batch write if the logger implements the LogWriter interface. The
+// redaction of the logger settings is applied to the ingested logs
+// before they are stored.
+//
+// The Auth hook receives the sources of the request, which is the source
+// filter for GET requests (empty if all of the sources are queried) and
+// the unique sources of the logs for POST requests.
+func LogsHandler(logger Logger, opts *LogsHandlerOptions) http.Handler {
+	o := LogsHandlerOptions{}
+	if opts != nil {
+		o = *opts
+	}
+
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = defaultLogsMaxLimit
+	}
+
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = defaultLogsMaxBodySize
+	}
+
+	if o.MaxBatch <= 0 {
+		o.MaxBatch = defaultLogsMaxBatch
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			o.findLogs(logger, w, r)
+		case http.MethodPost:
+			o.ingestLogs(logger, w, r)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			writeJSONError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		}
+	})
+}
+
+func (o *LogsHandlerOptions) findLogs(logger Logger, w http.ResponseWriter, r *http.Request) {
+	filter, err := parseLogsQuery(r.URL.String())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if o.Auth != nil {
+		if err := o.Auth(r, filter.authSources()); err != nil {
+			writeJSONError(w, http.StatusUnauthorized, err)
+			return
+		}
+	}
+
+	// a request without a limit would return all of the logs
+	if filter.Limit <= 0 || filter.Limit > o.MaxLimit {
+		filter.Limit = o.MaxLimit
+	}
+
+	page, err := findLogsPage(logger, *filter, o.MaxLimit)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (o *LogsHandlerOptions) ingestLogs(logger Logger, w http.ResponseWriter, r *http.Request) {
+	var body []LogPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, o.MaxBodySize)).Decode(&body); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("body is larger than %v bytes", o.MaxBodySize))
+			return
+		}
+
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %v", err))
+		return
+	}
+
+	if len(body) > o.MaxBatch {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("max %v logs can be sent in a single request", o.MaxBatch))
+		return
+	}
+
+	logs, err := ParseLogs(body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if o.Auth != nil {
+		var sources []string
+		seen := make(map[string]bool)
+		for _, log := range logs {
+			if !seen[log.Source] {
+				seen[log.Source] = true
+				sources = append(sources, log.Source)
+			}
+		}
+
+		if err := o.Auth(r, sources); err != nil {
+			writeJSONError(w, http.StatusUnauthorized, err)
+			return
+		}
+	}
+
+	if err := writeLogs(logger, logs); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]int{"inserted": len(logs)})
+}
+
+// authSources returns the sources of the filter which are passed to the Auth
+// hook. If the filter does not have any sources, all of the sources are
+// queried, which is represented by the empty source.
+func (f LogFilter) authSources() []string {
+	if sources := f.sources(); len(sources) > 0 {
+		return sources
+	}
+
+	return []string{""}
+}
+
+// APIKeyAuth returns an Auth hook for the LogsHandler which requires the
+// header to have the key of every requested source. The keys map has
+// the source as the key and the api key as the value. Querying the logs
+// of all sources requires the key of the empty source.
+func APIKeyAuth(header string, keys map[string]string) func(r *http.Request, sources []string) error {
+	return func(r *http.Request, sources []string) error {
+		key := r.Header.Get(header)
+		if key == "" {
+			return fmt.Errorf("missing %v header", header)
+		}
+
+		for _, source := range sources {
+			expected, ok := keys[source]
+			if !ok || subtle.ConstantTimeCompare([]byte(expected), []byte(key)) != 1 {
+				return fmt.Errorf("invalid api key for source %q", source)
+			}
+		}
+
+		return nil
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,298 @@
+package syro
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LogBroadcaster sends the published logs to the subscribers of the
+// LogStreamHandler. The last logs are kept in a backlog, so that the
+// clients can resume the stream with the Last-Event-ID header.
+type LogBroadcaster struct {
+	mu      sync.Mutex
+	size    int
+	seq     uint64
+	backlog []broadcastLog
+	subs    map[chan broadcastLog]struct{}
+}
+
+// broadcastLog is a published log with its sequence number, which is used
+// as the id of the event.
+type broadcastLog struct {
+	id  uint64
+	log Log
+}
+
+const (
+	defaultBroadcastBacklog = 1_000
+	broadcastSubscriberSize = 100
+	logStreamHeartbeat      = 15 * time.Second
+)
+
+// NewLogBroadcaster creates a broadcaster which keeps the last backlog logs.
+// If backlog is not positive, 1000 logs are kept.
+func NewLogBroadcaster(backlog int) *LogBroadcaster {
+	if backlog <= 0 {
+		backlog = defaultBroadcastBacklog
+	}
+
+	return &LogBroadcaster{size: backlog, subs: make(map[chan broadcastLog]struct{})}
+}
+
+// Publish sends the log to the subscribers. Logs are dropped for the
+// subscribers which are too slow to receive them.
+func (b *LogBroadcaster) Publish(log Log) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	entry := broadcastLog{id: b.seq, log: log}
+
+	b.backlog = append(b.backlog, entry)
+	if len(b.backlog) > b.size {
+		b.backlog = append([]broadcastLog{}, b.backlog[len(b.backlog)-b.size:]...)
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- entry:
+		default:
+		}
+	}
+}
+
+// WriteLogs publishes the logs, so that the broadcaster can be used as a
+// LogWriter.
+func (b *LogBroadcaster) WriteLogs(logs []Log) error {
+	for _, log := range logs {
+		b.Publish(log)
+	}
+
+	return nil
+}
+
+// subscribe returns a channel which receives the new logs. If resume is
+// true, the logs of the backlog which were published after the lastID are
+// returned as well.
+func (b *LogBroadcaster) subscribe(lastID uint64, resume bool) ([]broadcastLog, chan broadcastLog, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []broadcastLog
+	for _, entry := range b.backlog {
+		if resume && entry.id > lastID {
+			missed = append(missed, entry)
+		}
+	}
+
+	ch := make(chan broadcastLog, broadcastSubscriberSize)
+	b.subs[ch] = struct{}{}
+
+	return missed, ch, func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}
+}
+
+// Wrap returns a logger which writes the logs with the provided logger and
+// publishes them to the broadcaster.
+func (b *LogBroadcaster) Wrap(logger Logger) Logger {
+	return &BroadcastLogger{Logger: logger, broadcaster: b}
+}
+
+// BroadcastLogger is a Logger which publishes the logs of the wrapped logger
+// to the LogBroadcaster. Created with LogBroadcaster.Wrap.
+type BroadcastLogger struct {
+	Logger
+	broadcaster *LogBroadcaster
+}
+
+var _ Logger = (*BroadcastLogger)(nil)
+
+func (lg *BroadcastLogger) log(level LogLevel, msg string, lf ...LogFields) error {
+	_, _, err := lg.record(level, msg, lf...)
+	return err
+}
+
+// record writes the log with the wrapped logger and publishes the written
+// log, so that the subscribers receive the same id and timestamp as the
+// stored log.
+func (lg *BroadcastLogger) record(level LogLevel, msg string, lf ...LogFields) (Log, bool, error) {
+	log, ok, err := recordLog(lg.Logger, level, msg, lf...)
+	if ok {
+		lg.broadcaster.Publish(log)
+	}
+
+	return log, ok, err
+}
+
+func (lg *BroadcastLogger) wrap(logger Logger) Logger { return lg.broadcaster.Wrap(logger) }
+
+func (lg *BroadcastLogger) WithSource(v string) Logger   { return lg.wrap(lg.Logger.WithSource(v)) }
+func (lg *BroadcastLogger) WithEvent(v string) Logger    { return lg.wrap(lg.Logger.WithEvent(v)) }
+func (lg *BroadcastLogger) WithEventID(v string) Logger  { return lg.wrap(lg.Logger.WithEventID(v)) }
+func (lg *BroadcastLogger) With(fields LogFields) Logger { return lg.wrap(lg.Logger.With(fields)) }
+
+func (lg *BroadcastLogger) Debug(msg string, lf ...LogFields) error { return lg.log(DEBUG, msg, lf...) }
+func (lg *BroadcastLogger) Trace(msg string, lf ...LogFields) error { return lg.log(TRACE, msg, lf...) }
+func (lg *BroadcastLogger) Error(msg string, lf ...LogFields) error { return lg.log(ERROR, msg, lf...) }
+func (lg *BroadcastLogger) Info(msg string, lf ...LogFields) error  { return lg.log(INFO, msg, lf...) }
+func (lg *BroadcastLogger) Warn(msg string, lf ...LogFields) error  { return lg.log(WARN, msg, lf...) }
+func (lg *BroadcastLogger) Fatal(msg string, lf ...LogFields) error { return lg.log(FATAL, msg, lf...) }
+
+// WriteLogs writes the logs with the wrapped logger and publishes them. The
+// logs without an id get one before they are written, so that the published
+// logs have the same id as the stored ones.
+func (lg *BroadcastLogger) WriteLogs(logs []Log) error {
+	logs = append([]Log{}, logs...)
+	for i := range logs {
+		if logs[i].ID == "" {
+			logs[i].ID = newRandomID()
+		}
+	}
+
+	err := writeLogs(lg.Logger, logs)
+	for _, log := range enabledLogs(lg.GetProps().Settings, logs) {
+		lg.broadcaster.Publish(log)
+	}
+
+	return err
+}
+
+// FindLogsPage returns a page of the logs of the wrapped logger, so that the
+// cursors of the wrapped logger are kept.
+func (lg *BroadcastLogger) FindLogsPage(filter LogFilter, maxLimit int64) (*Page[Log], error) {
+	return findLogsPage(lg.Logger, filter, maxLimit)
+}
+
+// AggregateLogs counts the logs of the wrapped logger.
+func (lg *BroadcastLogger) AggregateLogs(filter LogFilter, groupBy []string, bucket time.Duration) ([]LogAggregation, error) {
+	aggregator, ok := lg.Logger.(LogAggregator)
+	if !ok {
+		return nil, fmt.Errorf("%v logger does not support aggregations", lg.Logger.Name())
+	}
+
+	return aggregator.AggregateLogs(filter, groupBy, bucket)
+}
+
+// LogStreamOptions are the options of the LogStreamHandler.
+type LogStreamOptions struct {
+	Auth func(r *http.Request, sources []string) error // Optional. Called before the stream is started, the request is rejected if it returns an error
+}
+
+// LogStreamHandler returns a handler which streams the published logs as
+// server-sent events. The logs can be filtered with the same URL params
+// as the ones used by RequestLogs, except for the time range and the
+// pagination.
+//
+// The id of the event is the sequence number of the log. Only the new logs
+// are sent, unless the request has the Last-Event-ID header, in which case
+// the logs from the backlog which were published after it are sent first.
+// The Auth hook receives the same sources as the one of the LogsHandler.
+func LogStreamHandler(b *LogBroadcaster, opts *LogStreamOptions) http.Handler {
+	o := LogStreamOptions{}
+	if opts != nil {
+		o = *opts
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeJSONError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+
+		filter, err := parseLogsQuery(r.URL.String())
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if o.Auth != nil {
+			if err := o.Auth(r, filter.authSources()); err != nil {
+				writeJSONError(w, http.StatusUnauthorized, err)
+				return
+			}
+		}
+
+		// the time range and the pagination are not used for the stream
+		filter.TimeseriesFilter = TimeseriesFilter{}
+
+		matches, err := filter.matcher()
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var lastID uint64
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID != "" {
+			if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+				writeJSONError(w, http.StatusBadRequest, errors.New("invalid Last-Event-ID header"))
+				return
+			}
+		}
+
+		rc := http.NewResponseController(w)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		missed, ch, unsubscribe := b.subscribe(lastID, lastEventID != "")
+		defer unsubscribe()
+
+		send := func(entry broadcastLog) error {
+			if !matches(&entry.log) {
+				return nil
+			}
+
+			data, err := json.Marshal(entry.log)
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", entry.id, data)
+			return err
+		}
+
+		for _, entry := range missed {
+			if err := send(entry); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(logStreamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case entry := <-ch:
+				if err := send(entry); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			}
+
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
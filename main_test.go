@@ -1,16 +1,26 @@
 package syro
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/robfig/cron/v3"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -513,3 +523,2308 @@ func TestJobLocker(t *testing.T) {
 		}
 	})
 }
+
+func TestCronConfig(t *testing.T) {
+	registry := NewJobRegistry().
+		Add("download", func(ctx context.Context) error { return nil }).
+		Add("publish", func(ctx context.Context) error { return nil })
+
+	t.Run("test-invalid-config", func(t *testing.T) {
+		s := NewCronScheduler(cron.New(), "test").WithRegistry(registry)
+
+		err := s.ApplyConfig(&CronConfig{Jobs: []JobConfig{
+			{Name: "download", Schedule: "not a schedule"},
+			{Name: "publish", Schedule: "@every 1h", Timeout: "1 minute"},
+			{Name: "missing", Schedule: "@every 1h"},
+		}})
+
+		if err == nil {
+			t.Fatal("ApplyConfig should return an error")
+		}
+
+		var eg *ErrGroup
+		if !errors.As(err, &eg) || eg.Len() != 3 {
+			t.Fatalf("expected all 3 problems to be reported, got: %v", err)
+		}
+
+		if len(s.Jobs) != 0 {
+			t.Fatal("no jobs should be registered if the config is invalid")
+		}
+	})
+
+	t.Run("test-unknown-dependency", func(t *testing.T) {
+		s := NewCronScheduler(cron.New(), "test").WithRegistry(registry)
+		if err := s.Register(&Job{Name: "extract", Schedule: "@every 1h", Func: func() error { return nil }}); err != nil {
+			t.Fatal(err)
+		}
+
+		err := s.ApplyConfig(&CronConfig{Jobs: []JobConfig{
+			{Name: "download", DependsOn: []string{"extract"}},
+			{Name: "publish", DependsOn: []string{"download", "aa"}},
+		}})
+
+		if err == nil || !strings.Contains(err.Error(), "job publish: unknown dependency aa") {
+			t.Fatalf("expected the unknown dependency to be reported, got: %v", err)
+		}
+
+		if len(s.Jobs) != 1 {
+			t.Fatal("no jobs should be registered if the config is invalid")
+		}
+	})
+
+	t.Run("test-load-and-reload", func(t *testing.T) {
+		c := cron.New()
+		s := NewCronScheduler(c, "test").WithRegistry(registry)
+
+		path := filepath.Join(t.TempDir(), "jobs.yaml")
+		write := func(data string) {
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		write(`
+jobs:
+  - name: download
+    schedule: "@every 1h"
+    timeout: 30s
+    retries: 2
+    tags: [etl]
+  - name: publish-daily
+    func: publish
+    schedule: "0 0 * * *"
+`)
+		if err := s.LoadConfig(path); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(s.Jobs) != 2 || len(c.Entries()) != 2 {
+			t.Fatalf("expected 2 registered jobs, got %v", len(s.Jobs))
+		}
+
+		download := s.findJob("download")
+		if download.Timeout != 30*time.Second || download.Retries != 2 || download.Tags[0] != "etl" {
+			t.Fatal("the config values are not applied to the job")
+		}
+
+		// reschedule download, remove publish-daily
+		write(`
+jobs:
+  - name: download
+    schedule: "@every 2h"
+`)
+		if err := s.LoadConfig(path); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(s.Jobs) != 1 || len(c.Entries()) != 1 {
+			t.Fatalf("expected 1 registered job, got %v", len(s.Jobs))
+		}
+
+		if s.findJob("download").Schedule != "@every 2h" {
+			t.Fatal("the job should be rescheduled")
+		}
+
+		// the schedule with seconds is valid in the config, but the cron
+		// instance does not support it, so the previous job is kept
+		write(`
+jobs:
+  - name: download
+    schedule: "0 0 0 * * *"
+`)
+		if err := s.LoadConfig(path); err == nil {
+			t.Fatal("expected an error for the schedule which cannot be registered")
+		}
+
+		if len(s.Jobs) != 1 || len(c.Entries()) != 1 || s.findJob("download").Schedule != "@every 2h" {
+			t.Fatal("the previous definition of the job should be kept")
+		}
+	})
+
+	t.Run("test-job-registered-in-code", func(t *testing.T) {
+		s := NewCronScheduler(cron.New(), "test").WithRegistry(registry)
+		if err := s.Register(&Job{Name: "download", Schedule: "@every 1h", Func: func() error { return nil }}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.ApplyConfig(&CronConfig{Jobs: []JobConfig{{Name: "download", Schedule: "@every 1h"}}}); err == nil {
+			t.Fatal("config should not override jobs registered in code")
+		}
+	})
+}
+
+func TestJobRetries(t *testing.T) {
+	attempts := 0
+	j := &Job{
+		Retries: 2,
+		FuncCtx: func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("failed")
+			}
+			return nil
+		},
+	}
+
+	if err := j.run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %v", attempts)
+	}
+}
+
+func TestMongoCronStorage(t *testing.T) {
+	opt := options.Client().ApplyURI("mongodb://localhost:27017")
+
+	conn, err := mongo.Connect(context.Background(), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Disconnect(context.Background())
+
+	db := conn.Database("test")
+	listColl := db.Collection("test_syro_cron_list")
+	historyColl := db.Collection("test_syro_cron_history")
+
+	for _, coll := range []*mongo.Collection{listColl, historyColl} {
+		if err := coll.Drop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	storage, err := NewMongoCronStorage(listColl, historyColl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.CreateIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("test-find-cron-jobs", func(t *testing.T) {
+		jobs := []struct {
+			source, name string
+			tags         []string
+			err          error
+		}{
+			{"app-1", "download-prices", []string{"etl", "prices"}, nil},
+			{"app-1", "download-volumes", []string{"etl"}, errors.New("failed")},
+			{"app-2", "cleanup", nil, nil},
+		}
+
+		for _, j := range jobs {
+			if err := storage.RegisterJob(j.source, j.name, "@every 1h", "", j.tags, JobStatusDone, j.err); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		exitWithErr := true
+		tests := []struct {
+			filter   CronJobFilter
+			expected int
+		}{
+			{CronJobFilter{}, 3},
+			{CronJobFilter{Source: "app-1"}, 2},
+			{CronJobFilter{Name: "^download-"}, 2},
+			{CronJobFilter{Tags: []string{"etl", "prices"}}, 1},
+			{CronJobFilter{ExitWithErr: &exitWithErr}, 1},
+			{CronJobFilter{Status: JobStatusRunning}, 0},
+			{CronJobFilter{TimeseriesFilter: TimeseriesFilter{From: time.Now().Add(time.Hour)}}, 0},
+			{CronJobFilter{TimeseriesFilter: TimeseriesFilter{Limit: 1}}, 1},
+		}
+
+		for i, tt := range tests {
+			if tt.filter.Limit == 0 {
+				tt.filter.Limit = 100
+			}
+
+			docs, err := storage.FindCronJobs(tt.filter, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(docs) != tt.expected {
+				t.Fatalf("test %v: expected %v jobs, got %v", i, tt.expected, len(docs))
+			}
+		}
+	})
+}
+
+func TestJobLimiter(t *testing.T) {
+	t.Run("test-group-limit", func(t *testing.T) {
+		l := newJobLimiter(0, map[string]int{"db": 1})
+
+		release, ok := l.acquire("db", 0)
+		if !ok {
+			t.Fatal("the first job should acquire the slot")
+		}
+
+		if _, ok := l.acquire("db", 0); ok {
+			t.Fatal("the second job of the group should be throttled")
+		}
+
+		if _, ok := l.acquire("other", 0); !ok {
+			t.Fatal("jobs of other groups should not be limited")
+		}
+
+		release()
+
+		if _, ok := l.acquire("db", 0); !ok {
+			t.Fatal("the slot should be free after the release")
+		}
+	})
+
+	t.Run("test-global-limit-with-wait", func(t *testing.T) {
+		l := newJobLimiter(1, nil)
+
+		release, ok := l.acquire("", 0)
+		if !ok {
+			t.Fatal("the first job should acquire the slot")
+		}
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			release()
+		}()
+
+		if _, ok := l.acquire("", time.Second); !ok {
+			t.Fatal("the second job should wait for the released slot")
+		}
+
+		if _, ok := l.acquire("", 10*time.Millisecond); ok {
+			t.Fatal("the third job should be throttled after the wait")
+		}
+	})
+
+	t.Run("test-group-slot-released-on-global-limit", func(t *testing.T) {
+		l := newJobLimiter(1, map[string]int{"db": 1})
+
+		if _, ok := l.acquire("", 0); !ok {
+			t.Fatal("the first job should acquire the slot")
+		}
+
+		if _, ok := l.acquire("db", 0); ok {
+			t.Fatal("the job should be throttled by the global limit")
+		}
+
+		if len(l.groups["db"]) != 0 {
+			t.Fatal("the group slot should not be taken if the job is throttled")
+		}
+	})
+
+	t.Run("test-group-wait-does-not-hold-global-slot", func(t *testing.T) {
+		l := newJobLimiter(2, map[string]int{"db": 1})
+
+		release, ok := l.acquire("db", 0)
+		if !ok {
+			t.Fatal("the first job should acquire the slot")
+		}
+		defer release()
+
+		waiting := make(chan bool)
+		go func() {
+			_, ok := l.acquire("db", 200*time.Millisecond)
+			waiting <- ok
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		if _, ok := l.acquire("other", 0); !ok {
+			t.Fatal("the job which waits for its group should not hold the global slot")
+		}
+
+		if <-waiting {
+			t.Fatal("the second job of the group should be throttled")
+		}
+	})
+}
+
+// recordingCronStorage is a CronStorage which keeps the registered executions
+// in memory, so that the scheduler can be tested without mongo.
+type recordingCronStorage struct {
+	mu    sync.Mutex
+	execs []CronExecLog
+}
+
+func (r *recordingCronStorage) FindCronJobs(CronJobFilter, int64) ([]CronJob, error) { return nil, nil }
+func (r *recordingCronStorage) RegisterJob(string, string, string, string, []string, JobStatus, error) error {
+	return nil
+}
+
+func (r *recordingCronStorage) RegisterExecution(ex *CronExecLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.execs = append(r.execs, *ex)
+	return nil
+}
+
+func (r *recordingCronStorage) FindExecutions(CronExecFilter, int64) ([]CronExecLog, error) {
+	return nil, nil
+}
+
+func (r *recordingCronStorage) FindExecutionsPage(CronExecFilter, int64) (*Page[CronExecLog], error) {
+	return &Page[CronExecLog]{}, nil
+}
+
+func (r *recordingCronStorage) SetJobsToInactive(string) error { return nil }
+
+// statuses returns the statuses of the executions of the job.
+func (r *recordingCronStorage) statuses(name string) []JobStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var statuses []JobStatus
+	for _, ex := range r.execs {
+		if ex.Name == name {
+			statuses = append(statuses, ex.Status)
+		}
+	}
+
+	return statuses
+}
+
+func TestJobThrottledExecution(t *testing.T) {
+	storage := &recordingCronStorage{}
+	s := NewCronScheduler(cron.New(), "test").WithStorage(storage).WithGroupLimit("db", 1)
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	logger := NewMemoryLogger(10, &LoggerSettings{DisableConsole: true})
+	jobs := []*Job{
+		{Name: "slow", Schedule: "@every 1h", ConcurrencyGroup: "db", Func: func() error { close(started); <-finish; return nil }},
+		{Name: "fast", Schedule: "@every 1h", ConcurrencyGroup: "db", Func: func() error { return nil }, Logger: logger},
+	}
+
+	for _, j := range jobs {
+		if err := s.Register(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	go s.execute(s.findJob("slow"), nil)
+	<-started
+
+	s.execute(s.findJob("fast"), nil)
+	close(finish)
+
+	if statuses := storage.statuses("fast"); len(statuses) != 1 || statuses[0] != JobStatusThrottled {
+		t.Fatalf("expected the throttled execution to be registered, got %v", statuses)
+	}
+
+	storage.mu.Lock()
+	runID := storage.execs[0].RunID
+	storage.mu.Unlock()
+
+	// the scheduler logs of the run can be correlated with the execution
+	logs := logger.Logs()
+	if len(logs) != 1 || logs[0].Message != "job throttled" || logs[0].EventID != runID {
+		t.Fatalf("expected the throttle log to have the run id as the event id, got %+v", logs)
+	}
+}
+
+func TestJobDependencies(t *testing.T) {
+	t.Run("test-cycle-detection", func(t *testing.T) {
+		s := NewCronScheduler(cron.New(), "test")
+		fn := func() error { return nil }
+
+		if err := s.Register(&Job{Name: "a", DependsOn: []string{"c"}, Func: fn}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Register(&Job{Name: "b", DependsOn: []string{"a"}, Func: fn}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Register(&Job{Name: "c", DependsOn: []string{"b"}, Func: fn}); err == nil {
+			t.Fatal("Register should detect the dependency cycle")
+		}
+
+		if err := s.Register(&Job{Name: "d", DependsOn: []string{"d"}, Func: fn}); err == nil {
+			t.Fatal("Register should detect the self dependency")
+		}
+
+		if err := s.Register(&Job{Name: "e", Func: fn}); err == nil {
+			t.Fatal("Register should fail without a schedule or dependencies")
+		}
+	})
+
+	t.Run("test-chain-shares-run-id", func(t *testing.T) {
+		s := NewCronScheduler(cron.New(), "test")
+
+		var mu sync.Mutex
+		runIDs := make(map[string]string)
+		done := make(chan struct{})
+
+		newJob := func(name string, deps ...string) *Job {
+			j := &Job{
+				Name:      name,
+				DependsOn: deps,
+				FuncCtx: func(ctx context.Context) error {
+					mu.Lock()
+					defer mu.Unlock()
+					runIDs[name] = JobRunID(ctx)
+					return nil
+				},
+			}
+
+			if len(deps) == 0 {
+				j.Schedule = "@every 1h"
+			}
+
+			return j
+		}
+
+		publish := newJob("publish", "transform", "download")
+		publish.OnComplete = func(error) { close(done) }
+
+		for _, j := range []*Job{newJob("download"), newJob("transform", "download"), publish} {
+			if err := s.Register(j); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		s.execute(s.findJob("download"), nil)
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the dependent jobs were not triggered")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(runIDs) != 3 || runIDs["download"] == "" {
+			t.Fatalf("expected all of the jobs to run, got %v", runIDs)
+		}
+
+		if runIDs["download"] != runIDs["transform"] || runIDs["download"] != runIDs["publish"] {
+			t.Fatalf("the run id should be shared across the chain, got %v", runIDs)
+		}
+	})
+
+	t.Run("test-skip-upstream-failed", func(t *testing.T) {
+		s := NewCronScheduler(cron.New(), "test")
+
+		var ran atomic.Bool
+		jobs := []*Job{
+			{Name: "download", Schedule: "@every 1h", Func: func() error { return errors.New("failed") }},
+			{Name: "transform", DependsOn: []string{"download"}, Func: func() error { ran.Store(true); return nil }},
+		}
+
+		for _, j := range jobs {
+			if err := s.Register(j); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		s.execute(jobs[0], nil)
+		time.Sleep(20 * time.Millisecond)
+
+		if ran.Load() {
+			t.Fatal("the dependent job should be skipped if the upstream job fails")
+		}
+	})
+
+	t.Run("test-independent-upstreams", func(t *testing.T) {
+		s := NewCronScheduler(cron.New(), "test")
+
+		var mu sync.Mutex
+		runIDs := make(map[string]string)
+		record := func(name string) func(ctx context.Context) error {
+			return func(ctx context.Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+				runIDs[name] = JobRunID(ctx)
+				return nil
+			}
+		}
+
+		var runs atomic.Int32
+		done := make(chan struct{}, 2)
+		jobs := []*Job{
+			{Name: "a", Schedule: "@every 1h", FuncCtx: record("a")},
+			{Name: "b", Schedule: "@every 2h", FuncCtx: record("b")},
+			{Name: "c", DependsOn: []string{"a", "b"}, FuncCtx: func(ctx context.Context) error { runs.Add(1); return record("c")(ctx) }, OnComplete: func(error) { done <- struct{}{} }},
+		}
+
+		for _, j := range jobs {
+			if err := s.Register(j); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// both of the upstream jobs are executed by their own schedule
+		s.execute(jobs[0], nil)
+		time.Sleep(20 * time.Millisecond)
+		if runs.Load() != 0 {
+			t.Fatal("the dependent job should wait for all of the upstream jobs")
+		}
+
+		s.execute(jobs[1], nil)
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the dependent job was not triggered after both upstream jobs succeeded")
+		}
+
+		// the upstream jobs finished in different runs, so a new run is started
+		mu.Lock()
+		if runIDs["c"] == "" || runIDs["c"] == runIDs["a"] || runIDs["c"] == runIDs["b"] {
+			t.Fatalf("expected a new run for the dependent job, got %v", runIDs)
+		}
+		mu.Unlock()
+
+		// the results are reset after the dependent job is triggered
+		s.execute(jobs[0], nil)
+		time.Sleep(20 * time.Millisecond)
+		if runs.Load() != 1 {
+			t.Fatalf("expected the dependent job to run once, got %v", runs.Load())
+		}
+	})
+
+	t.Run("test-busy-dependent-is-recorded", func(t *testing.T) {
+		storage := &recordingCronStorage{}
+		s := NewCronScheduler(cron.New(), "test").WithStorage(storage)
+
+		started, finish := make(chan struct{}), make(chan struct{})
+		var once sync.Once
+		jobs := []*Job{
+			{Name: "a", Schedule: "@every 1h", Func: func() error { return nil }},
+			{Name: "c", DependsOn: []string{"a"}, Func: func() error { once.Do(func() { close(started) }); <-finish; return nil }},
+		}
+
+		for _, j := range jobs {
+			if err := s.Register(j); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		s.execute(jobs[0], nil)
+		<-started
+
+		// the second trigger of c happens while the first one is running
+		s.execute(jobs[0], nil)
+		time.Sleep(20 * time.Millisecond)
+		close(finish)
+		time.Sleep(20 * time.Millisecond)
+
+		statuses := storage.statuses("c")
+		if len(statuses) != 2 || !slices.Contains(statuses, JobStatusBusy) || !slices.Contains(statuses, JobStatusDone) {
+			t.Fatalf("expected a done and a busy execution of the dependent job, got %v", statuses)
+		}
+	})
+}
+
+// testFindTasks checks that the storage applies the limit and the time range
+// of the filter in the same way for all of the backends.
+func testFindTasks(t *testing.T, storage TaskStorage) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		task := &Task{
+			ID:        fmt.Sprintf("find-task-%v", i),
+			Source:    "find-tasks",
+			Handler:   "noop",
+			Status:    TaskStatusPending,
+			RunAt:     ts.Add(time.Duration(i) * time.Hour),
+			CreatedAt: ts,
+		}
+
+		if err := storage.EnqueueTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter   TaskFilter
+		expected int
+	}{
+		{TaskFilter{}, 5}, // limit 0 means no limit
+		{TaskFilter{TimeseriesFilter: TimeseriesFilter{Limit: 2}}, 2},                    // limited
+		{TaskFilter{TimeseriesFilter: TimeseriesFilter{Limit: 10, Skip: 4}}, 1},          // skipped
+		{TaskFilter{TimeseriesFilter: TimeseriesFilter{From: ts.Add(3 * time.Hour)}}, 2}, // only from
+		{TaskFilter{TimeseriesFilter: TimeseriesFilter{To: ts.Add(time.Hour)}}, 2},       // only to
+		{TaskFilter{TimeseriesFilter: TimeseriesFilter{From: ts.Add(time.Hour), To: ts.Add(2 * time.Hour)}}, 2},
+	}
+
+	for i, tt := range tests {
+		tt.filter.Source = "find-tasks"
+
+		tasks, err := storage.FindTasks(tt.filter, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(tasks) != tt.expected {
+			t.Fatalf("test %v: expected %v tasks, got %v", i, tt.expected, len(tasks))
+		}
+	}
+
+	if _, err := storage.FindTasks(TaskFilter{TimeseriesFilter: TimeseriesFilter{From: ts.Add(time.Hour), To: ts}}, 100); err == nil {
+		t.Fatal("expected an error if the from date is after the to date")
+	}
+}
+
+func TestMongoTaskStorage(t *testing.T) {
+	conn, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Disconnect(context.Background())
+
+	coll := conn.Database("test").Collection("test_syro_tasks")
+	if err := coll.Drop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	storage, err := NewMongoTaskStorage(coll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testFindTasks(t, storage)
+}
+
+func TestTaskQueue(t *testing.T) {
+	t.Run("test-find-tasks", func(t *testing.T) { testFindTasks(t, NewMemoryTaskStorage()) })
+
+	t.Run("test-enqueue-and-process", func(t *testing.T) {
+		storage := NewMemoryTaskStorage()
+
+		var received string
+		q := NewTaskQueue(storage, "test").Handle("send-email", func(ctx context.Context, payload []byte) error {
+			return json.Unmarshal(payload, &received)
+		})
+
+		if _, err := q.EnqueueAfter("send-email", "hello", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		task, err := q.EnqueueAt("send-email", "world", time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		processed, err := q.processNext(context.Background(), "worker-1")
+		if err != nil || !processed {
+			t.Fatalf("expected the due task to be processed, got %v %v", processed, err)
+		}
+
+		if received != "world" {
+			t.Fatalf("expected the payload of the due task, got %q", received)
+		}
+
+		if processed, _ := q.processNext(context.Background(), "worker-1"); processed {
+			t.Fatal("the delayed task should not be processed yet")
+		}
+
+		docs, err := storage.FindTasks(TaskFilter{Status: TaskStatusDone, TimeseriesFilter: TimeseriesFilter{Limit: 10}}, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 1 || docs[0].ID != task.ID {
+			t.Fatal("the processed task should have the done status")
+		}
+	})
+
+	t.Run("test-retries-and-dead-letter", func(t *testing.T) {
+		storage := NewMemoryTaskStorage()
+		q := NewTaskQueue(storage, "test").Handle("fail", func(ctx context.Context, payload []byte) error {
+			return errors.New("failed")
+		})
+		q.MaxAttempts = 2
+		q.RetryDelay = 0
+
+		if _, err := q.EnqueueAt("fail", nil, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+
+		for range 3 {
+			if _, err := q.processNext(context.Background(), "worker-1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		docs, err := storage.FindTasks(TaskFilter{TimeseriesFilter: TimeseriesFilter{Limit: 10}}, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 1 || docs[0].Status != TaskStatusDead || docs[0].Attempts != 2 {
+			t.Fatalf("expected a dead task after 2 attempts, got %+v", docs)
+		}
+	})
+
+	t.Run("test-struct-literal-and-timeout", func(t *testing.T) {
+		storage := NewMemoryTaskStorage()
+
+		// the timeout of the handler is independent of the lease
+		q := (&TaskQueue{Source: "test", Storage: storage, MaxAttempts: 1, Lease: time.Hour, Timeout: 10 * time.Millisecond}).
+			Handle("slow", func(ctx context.Context, payload []byte) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+
+		if _, err := q.EnqueueAt("slow", nil, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if _, err := q.processNext(context.Background(), "worker-1"); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the handler should be stopped after the timeout")
+		}
+
+		docs, err := storage.FindTasks(TaskFilter{}, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 1 || docs[0].Status != TaskStatusDead || !strings.Contains(docs[0].Error, "deadline exceeded") {
+			t.Fatalf("expected the task to fail with the timeout, got %+v", docs)
+		}
+	})
+
+	t.Run("test-expired-lease", func(t *testing.T) {
+		storage := NewMemoryTaskStorage()
+		q := NewTaskQueue(storage, "test")
+
+		if _, err := q.EnqueueAt("any", nil, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+
+		now := time.Now()
+		claimed, err := storage.ClaimTask("test", "worker-1", now, time.Minute)
+		if err != nil || claimed == nil {
+			t.Fatal("the task should be claimed")
+		}
+
+		if task, _ := storage.ClaimTask("test", "worker-2", now, time.Minute); task != nil {
+			t.Fatal("the leased task should not be claimed by another worker")
+		}
+
+		reclaimed, _ := storage.ClaimTask("test", "worker-2", now.Add(2*time.Minute), time.Minute)
+		if reclaimed == nil || reclaimed.WorkerID != "worker-2" {
+			t.Fatal("the task should be claimed again after the lease expired")
+		}
+
+		claimed.Status = TaskStatusDone
+		if err := storage.UpdateTask(claimed); err == nil {
+			t.Fatal("the previous worker should not be able to update the task")
+		}
+	})
+}
+
+func TestAsyncLogWriter(t *testing.T) {
+	newWriter := func(opts AsyncOptions, fail bool) (*asyncLogWriter, *atomic.Int64) {
+		var inserted atomic.Int64
+		w := newAsyncLogWriter(func(ctx context.Context, docs []any) (int, error) {
+			if fail {
+				return 0, errors.New("insert failed")
+			}
+
+			inserted.Add(int64(len(docs)))
+			return len(docs), nil
+		}, opts)
+
+		return w, &inserted
+	}
+
+	t.Run("test-flush-and-close", func(t *testing.T) {
+		w, inserted := newWriter(AsyncOptions{BatchSize: 3, FlushInterval: time.Hour}, false)
+
+		for i := range 10 {
+			if err := w.enqueue(i); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := w.flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if inserted.Load() != 10 || w.stats().Written != 10 {
+			t.Fatalf("expected 10 written logs after the flush, got %v", inserted.Load())
+		}
+
+		if err := w.enqueue(11); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if inserted.Load() != 11 {
+			t.Fatal("the queued logs should be written on close")
+		}
+
+		if err := w.enqueue(12); err == nil {
+			t.Fatal("enqueue should fail after close")
+		}
+	})
+
+	t.Run("test-overflow-policies", func(t *testing.T) {
+		for _, policy := range []OverflowPolicy{OverflowDropNewest, OverflowDropOldest} {
+			// the first full batch blocks the writer, so that the queue fills up
+			started, unblock := make(chan struct{}), make(chan struct{})
+			var once sync.Once
+			w := newAsyncLogWriter(func(ctx context.Context, docs []any) (int, error) {
+				once.Do(func() { close(started) })
+				<-unblock
+				return len(docs), nil
+			}, AsyncOptions{QueueSize: 5, BatchSize: 100, FlushInterval: time.Hour, OverflowPolicy: policy})
+
+			if w.opts.BatchSize != 5 {
+				t.Fatalf("expected the batch size to be clamped to the queue size, got %v", w.opts.BatchSize)
+			}
+
+			for i := range 5 {
+				w.enqueue(i)
+			}
+			<-started
+
+			for i := 5; i < 13; i++ {
+				w.enqueue(i)
+			}
+
+			w.mu.Lock()
+			first := w.queue[0]
+			w.mu.Unlock()
+
+			if w.stats().Dropped != 3 {
+				t.Fatalf("policy %v: expected 3 dropped logs, got %v", policy, w.stats().Dropped)
+			}
+
+			if policy == OverflowDropNewest && first != 5 || policy == OverflowDropOldest && first != 8 {
+				t.Fatalf("policy %v: unexpected first log in the queue: %v", policy, first)
+			}
+
+			close(unblock)
+			w.close(context.Background())
+		}
+	})
+
+	t.Run("test-failed-writes", func(t *testing.T) {
+		w, _ := newWriter(AsyncOptions{FlushInterval: time.Hour}, true)
+		w.enqueue(1)
+		w.enqueue(2)
+
+		if err := w.flush(context.Background()); err == nil {
+			t.Fatal("flush should return the insert error")
+		}
+
+		if w.stats().Failed != 2 {
+			t.Fatalf("expected 2 failed logs, got %v", w.stats().Failed)
+		}
+
+		w.enqueue(3)
+		if err := w.close(context.Background()); err == nil {
+			t.Fatal("close should return the error of the final write")
+		}
+	})
+
+	t.Run("test-with-async", func(t *testing.T) {
+		base := NewMongoLogger(nil, nil)
+
+		first := base.WithAsync(AsyncOptions{})
+		second := base.WithAsync(AsyncOptions{})
+
+		if base.async != nil || first.async == nil || first.async == second.async {
+			t.Fatal("expected every copy to own its writer and the receiver to be unchanged")
+		}
+
+		if child := first.WithSource("api").(*MongoLogger); child.async != first.async {
+			t.Fatal("expected the child logger to share the writer")
+		}
+
+		for _, lg := range []*MongoLogger{first, second} {
+			if err := lg.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestChildLoggers(t *testing.T) {
+	t.Run("test-with-returns-copy", func(t *testing.T) {
+		for _, parent := range []Logger{NewConsoleLogger(nil), NewMongoLogger(nil, nil)} {
+			child := parent.WithSource("my-source").WithEvent("my-event").WithEventID("my-event-id")
+
+			if props := parent.GetProps(); props.Source != "" || props.Event != "" || props.EventID != "" {
+				t.Fatalf("%v: the parent logger should not be modified, got %+v", parent.Name(), props)
+			}
+
+			if props := child.GetProps(); props.Source != "my-source" || props.Event != "my-event" || props.EventID != "my-event-id" {
+				t.Fatalf("%v: the child logger does not have the values, got %+v", parent.Name(), props)
+			}
+		}
+	})
+
+	t.Run("test-default-fields", func(t *testing.T) {
+		parent := NewConsoleLogger(nil).With(LogFields{"service": "api", "version": 1})
+		child := parent.With(LogFields{"version": 2, "user": "john"})
+
+		if len(parent.GetProps().Fields) != 2 || parent.GetProps().Fields["version"] != 1 {
+			t.Fatal("the parent fields should not be modified")
+		}
+
+		fields := child.GetProps().Fields
+		if fields["service"] != "api" || fields["version"] != 2 || fields["user"] != "john" {
+			t.Fatalf("the child fields are not merged, got %v", fields)
+		}
+
+		merged := mergeFields(fields, LogFields{"user": "jane"})
+		if merged["user"] != "jane" || fields["user"] != "john" {
+			t.Fatal("the log fields should override the default fields without modifying them")
+		}
+
+		if mergeFields(nil) != nil {
+			t.Fatal("mergeFields should return nil if there are no fields")
+		}
+	})
+
+	// run with the -race flag
+	t.Run("test-concurrent-child-loggers", func(t *testing.T) {
+		for _, parent := range []Logger{NewConsoleLogger(nil), NewMongoLogger(nil, nil)} {
+			var wg sync.WaitGroup
+			for i := range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					id := fmt.Sprintf("request-%v", i)
+					child := parent.WithEventID(id).With(LogFields{"i": i})
+					if child.GetProps().EventID != id || child.GetProps().Fields["i"] != i {
+						t.Errorf("child logger %v leaked into another request", id)
+					}
+				}()
+			}
+			wg.Wait()
+		}
+	})
+}
+
+func TestLevelFiltering(t *testing.T) {
+	t.Run("test-min-level", func(t *testing.T) {
+		var settings *LoggerSettings
+		if !settings.Enabled(TRACE, "") {
+			t.Fatal("all levels should be enabled if the settings are nil")
+		}
+
+		settings = &LoggerSettings{
+			MinLevel:     INFO,
+			SourceLevels: map[string]LogLevel{"pooler": DEBUG},
+		}
+
+		if settings.Enabled(DEBUG, "api") || !settings.Enabled(WARN, "api") {
+			t.Fatal("the min level should be applied to the sources without an override")
+		}
+
+		if !settings.Enabled(DEBUG, "pooler") || settings.Enabled(TRACE, "pooler") {
+			t.Fatal("the source level should override the min level")
+		}
+	})
+
+	t.Run("test-runtime-changes", func(t *testing.T) {
+		settings := &LoggerSettings{MinLevel: ERROR}
+		logger := NewConsoleLogger(settings).WithSource("api")
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				logger.Trace("should not be printed")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			settings.SetSourceLevel("pooler", TRACE)
+		}()
+		wg.Wait()
+
+		if !settings.Enabled(TRACE, "pooler") {
+			t.Fatal("the source level should be changed at runtime")
+		}
+
+		settings.SetMinLevel(WARN)
+		if !settings.Enabled(WARN, "api") {
+			t.Fatal("the min level should be changed at runtime")
+		}
+
+		settings.RemoveSourceLevel("pooler")
+		if settings.Enabled(TRACE, "pooler") {
+			t.Fatal("the source should use the min level after the override is removed")
+		}
+	})
+}
+
+func TestSlog(t *testing.T) {
+	t.Run("test-slog-logger", func(t *testing.T) {
+		var buf strings.Builder
+		logger := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug - 4})), nil).
+			WithSource("my-source").
+			WithEventID("my-event-id")
+
+		if err := logger.Trace("my-message", LogFields{"key": "value"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := stringIncludes(buf.String(), []string{
+			`"level":"DEBUG-4"`,
+			`"msg":"my-message"`,
+			`"source":"my-source"`,
+			`"event_id":"my-event-id"`,
+			`"key":"value"`,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("test-slog-handler", func(t *testing.T) {
+		var buf strings.Builder
+		target := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)), &LoggerSettings{MinLevel: INFO})
+		logger := slog.New(NewSlogHandler(target, nil)).With("source", "api", "service", "users")
+
+		logger.Debug("should be dropped")
+		if buf.Len() != 0 {
+			t.Fatal("the min level of the logger should be respected")
+		}
+
+		logger.WithGroup("request").Error("failed", "id", 1, "err", errors.New("boom"), "event", "in-group")
+
+		if err := stringIncludes(buf.String(), []string{
+			`"level":"ERROR"`,
+			`"msg":"failed"`,
+			`"source":"api"`,
+			`"service":"users"`,
+			`"request":{`,
+			`"err":"boom"`,
+			`"event":"in-group"`,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("test-level-mapping", func(t *testing.T) {
+		for _, level := range []LogLevel{TRACE, DEBUG, INFO, WARN, ERROR, FATAL} {
+			if logLevelFromSlog(slogLevel(level)) != level {
+				t.Fatalf("level %v is not mapped back to itself", level)
+			}
+		}
+	})
+}
+
+// failingLogger is a Logger which returns an error for every log
+type failingLogger struct{ *ConsoleLogger }
+
+func (lg failingLogger) Info(msg string, lf ...LogFields) error  { return errors.New("sink failed") }
+func (lg failingLogger) Error(msg string, lf ...LogFields) error { return errors.New("sink failed") }
+
+func TestMultiLogger(t *testing.T) {
+	var all, warnings strings.Builder
+	allSink := NewSlogLogger(slog.New(slog.NewJSONHandler(&all, nil)), nil)
+	warnSink := NewSlogLogger(slog.New(slog.NewJSONHandler(&warnings, nil)), nil)
+
+	logger := NewMultiLogger(nil,
+		MultiSink{Logger: allSink},
+		MultiSink{Logger: warnSink, MinLevel: WARN},
+	).WithSource("api")
+
+	if err := logger.Info("info-log"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := logger.Error("error-log"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stringIncludes(all.String(), []string{"info-log", "error-log", `"source":"api"`}); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(warnings.String(), "info-log") || !strings.Contains(warnings.String(), "error-log") {
+		t.Fatal("the min level of the sink should be respected")
+	}
+
+	t.Run("test-sink-errors", func(t *testing.T) {
+		failing := failingLogger{NewConsoleLogger(&LoggerSettings{})}
+		logger := NewMultiLogger(nil, MultiSink{Logger: failing}, MultiSink{Logger: allSink}, MultiSink{Logger: failing})
+
+		var eg *ErrGroup
+		if err := logger.Info("qwe"); !errors.As(err, &eg) || eg.Len() != 2 {
+			t.Fatalf("expected the errors of both failing sinks, got %v", err)
+		}
+	})
+
+	t.Run("test-queryable-sink", func(t *testing.T) {
+		if _, err := logger.FindLogs(LogFilter{}, 10); err == nil {
+			t.Fatal("FindLogs should fail without a queryable sink")
+		}
+
+		queryable := NewMultiLogger(nil).WithQueryable(NewConsoleLogger(nil))
+		if _, err := queryable.LogExists(nil); err == nil || err.Error() != "method cannot be used with ConsoleLogger" {
+			t.Fatal("LogExists should use the queryable sink")
+		}
+	})
+}
+
+func TestFileLogger(t *testing.T) {
+	dir := t.TempDir()
+	settings := &LoggerSettings{DisableConsole: true}
+
+	logger, err := NewFileLogger(FileLoggerOptions{Dir: dir, MaxSize: 1_000, MaxFiles: 3}, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	// write a log of the previous day, so that the first log of today is rotated
+	yesterday := NewLog(INFO, "first log", "api", "", "yesterday")
+	yesterday.Timestamp = yesterday.Timestamp.Add(-24 * time.Hour)
+	if err := logger.w.write(yesterday); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().UTC()
+	for i := range 30 {
+		if err := logger.WithSource("api").Debug("log with fields", LogFields{"i": i}); err != nil {
+			t.Fatal(err)
+		}
+
+		// avoid files with the same start time
+		time.Sleep(time.Millisecond)
+	}
+
+	files, err := logger.w.files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rotated int
+	for _, f := range files {
+		if f.rotated {
+			rotated++
+		}
+	}
+
+	if rotated != 3 {
+		t.Fatalf("expected 3 rotated files after pruning, got %v", rotated)
+	}
+
+	t.Run("test-find-logs", func(t *testing.T) {
+		logs, err := logger.FindLogs(LogFilter{Source: "api", TimeseriesFilter: TimeseriesFilter{Limit: 5}}, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(logs) != 5 || logs[0].Fields["i"] != float64(29) {
+			t.Fatalf("expected the 5 newest logs, got %v", logs)
+		}
+
+		logs, err = logger.FindLogs(LogFilter{Source: "api"}, 1_000)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(logs) == 0 || len(logs) >= 30 {
+			t.Fatalf("the logs of the pruned files should not be returned, got %v", len(logs))
+		}
+	})
+
+	t.Run("test-log-exists", func(t *testing.T) {
+		if _, err := logger.LogExists(bson.M{}); err == nil {
+			t.Fatal("LogExists should require a LogFilter")
+		}
+
+		exists, err := logger.LogExists(LogFilter{Source: "api", EventID: "yesterday"})
+		if err != nil || exists {
+			t.Fatalf("the log should not exist, got %v %v", exists, err)
+		}
+	})
+
+	t.Run("test-concurrent-reads", func(t *testing.T) {
+		dir := t.TempDir()
+		logger, err := NewFileLogger(FileLoggerOptions{Dir: dir, MaxSize: 500}, settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logger.Close()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := range 50 {
+				logger.Info("log", LogFields{"i": i})
+				time.Sleep(time.Millisecond)
+			}
+		}()
+
+		// the archives are renamed into place when they are complete
+		for reading := true; reading; {
+			select {
+			case <-done:
+				reading = false
+			default:
+			}
+
+			if _, err := logger.FindLogs(LogFilter{}, 1_000); err != nil {
+				t.Fatalf("expected the logs to be read while rotating, got %v", err)
+			}
+		}
+
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".tmp") {
+				t.Fatalf("the temp file of the archive should be renamed, got %v", e.Name())
+			}
+		}
+	})
+
+	t.Run("test-rotate-previous-run", func(t *testing.T) {
+		logger.Close()
+
+		reopened, err := NewFileLogger(FileLoggerOptions{Dir: dir, MaxFiles: 10}, settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files, _ := reopened.w.files()
+		for _, f := range files {
+			if !f.rotated {
+				t.Fatal("the active file of the previous run should be rotated")
+			}
+		}
+
+		exists, err := reopened.LogExists(LogFilter{Source: "api", TimeseriesFilter: TimeseriesFilter{From: start, To: time.Now()}})
+		if err != nil || !exists {
+			t.Fatalf("the logs of the rotated files should be found, got %v %v", exists, err)
+		}
+	})
+}
+
+func TestMemoryLogger(t *testing.T) {
+	logger := NewMemoryLogger(5, &LoggerSettings{DisableConsole: true})
+
+	logs, cancel := logger.Subscribe()
+	defer cancel()
+
+	for i := range 8 {
+		if err := logger.WithSource("api").With(LogFields{"i": i}).Info("log"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := logger.WithEvent("job").Error("failed"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("test-ring-buffer", func(t *testing.T) {
+		all := logger.Logs()
+		if len(all) != 5 {
+			t.Fatalf("expected 5 logs in the buffer, got %v", len(all))
+		}
+
+		if all[0].Fields["i"] != 4 || all[4].Message != "failed" {
+			t.Fatalf("unexpected order of the logs: %v", all)
+		}
+	})
+
+	t.Run("test-find-logs", func(t *testing.T) {
+		found, err := logger.FindLogs(LogFilter{Source: "api", TimeseriesFilter: TimeseriesFilter{Limit: 2, Skip: 1}}, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(found) != 2 || found[0].Fields["i"] != 6 || found[1].Fields["i"] != 5 {
+			t.Fatalf("unexpected logs: %v", found)
+		}
+
+		lvl := ERROR
+		exists, err := logger.LogExists(LogFilter{Level: &lvl, Event: "job"})
+		if err != nil || !exists {
+			t.Fatalf("expected the error log to exist, got %v, %v", exists, err)
+		}
+
+		if _, err := logger.FindLogs(LogFilter{TimeseriesFilter: TimeseriesFilter{From: time.Now(), To: time.Now().Add(-time.Hour)}}, 100); err == nil {
+			t.Fatal("expected an error for an invalid time range")
+		}
+	})
+
+	t.Run("test-subscribe", func(t *testing.T) {
+		for i := range 9 {
+			log := <-logs
+			if i == 8 && log.Message != "failed" {
+				t.Fatalf("expected the last log to be the error, got %v", log.Message)
+			}
+		}
+
+		cancel()
+		if _, ok := <-logs; ok {
+			t.Fatal("expected the channel to be closed")
+		}
+	})
+
+	logger.Reset()
+	if len(logger.Logs()) != 0 {
+		t.Fatal("expected the buffer to be empty after reset")
+	}
+}
+
+func TestContextLogger(t *testing.T) {
+	logger := NewMemoryLogger(10, &LoggerSettings{DisableConsole: true})
+
+	t.Run("test-from-context", func(t *testing.T) {
+		if FromContext(context.Background()) == nil {
+			t.Fatal("expected a default logger without a logger in the context")
+		}
+
+		ctx := ContextWithLogger(context.Background(), logger.WithSource("api"))
+		ctx = ContextWithTraceID(ctx, "trace-1")
+
+		if err := FromContext(ctx).Info("with trace id"); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx = ContextWithEventID(ctx, "event-1")
+		if err := FromContext(ctx).Info("with event id"); err != nil {
+			t.Fatal(err)
+		}
+
+		logs := logger.Logs()
+		if len(logs) != 2 {
+			t.Fatalf("expected 2 logs, got %v", len(logs))
+		}
+
+		if logs[0].EventID != "trace-1" || logs[0].Source != "api" {
+			t.Fatalf("expected the trace id as the event id, got %v", logs[0])
+		}
+
+		if logs[1].EventID != "event-1" || logs[1].Fields["trace_id"] != "trace-1" {
+			t.Fatalf("expected the event id and the trace id field, got %v", logs[1])
+		}
+	})
+
+	t.Run("test-job-context", func(t *testing.T) {
+		logger.Reset()
+		s := NewCronScheduler(cron.New(), "test")
+
+		var runID string
+		job := &Job{
+			Name:     "job",
+			Schedule: "@every 1h",
+			Logger:   logger.WithSource("cron"),
+			FuncCtx: func(ctx context.Context) error {
+				runID = JobRunID(ctx)
+				return FromContext(ctx).Info("job log")
+			},
+		}
+
+		if err := s.Register(job); err != nil {
+			t.Fatal(err)
+		}
+
+		s.execute(job, nil)
+
+		logs := logger.Logs()
+		if len(logs) != 1 || logs[0].EventID != runID || logs[0].Source != "cron" {
+			t.Fatalf("expected the job log with the run id %v, got %v", runID, logs)
+		}
+	})
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	logger := NewMemoryLogger(10, &LoggerSettings{DisableConsole: true})
+	middleware := HTTPMiddleware(logger, &HTTPMiddlewareOptions{Source: "api"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("loading user", LogFields{"id": r.PathValue("id")})
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("GET /fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "failed", http.StatusInternalServerError)
+	})
+
+	handler := middleware(mux)
+
+	t.Run("test-incoming-event-id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Header().Get("X-Request-ID") != "req-1" {
+			t.Fatalf("expected the event id in the response, got %v", rec.Header().Get("X-Request-ID"))
+		}
+
+		logs := logger.Logs()
+		if len(logs) != 2 {
+			t.Fatalf("expected 2 logs, got %v", len(logs))
+		}
+
+		for _, log := range logs {
+			if log.EventID != "req-1" || log.Source != "api" || log.Event != "GET" {
+				t.Fatalf("unexpected log properties: %v", log)
+			}
+		}
+
+		if logs[1].Level != INFO || logs[1].Fields["status"] != 200 || logs[1].Fields["bytes"] != 2 || logs[1].Fields["path"] != "/users/1" {
+			t.Fatalf("unexpected request log: %v", logs[1])
+		}
+	})
+
+	t.Run("test-generated-event-id", func(t *testing.T) {
+		logger.Reset()
+
+		req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+		req.Header.Set("X-Request-ID", "invalid id\n")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		logs := logger.Logs()
+		if len(logs) != 1 || logs[0].Level != ERROR || logs[0].Fields["status"] != 500 {
+			t.Fatalf("expected an error log for the failed request, got %v", logs)
+		}
+
+		if id := rec.Header().Get("X-Request-ID"); id == "" || id != logs[0].EventID {
+			t.Fatalf("expected a generated event id, got %q", id)
+		}
+	})
+}
+
+func TestLogsHandler(t *testing.T) {
+	logger := NewMemoryLogger(100, &LoggerSettings{DisableConsole: true})
+	handler := LogsHandler(logger, &LogsHandlerOptions{
+		MaxLimit:    2,
+		MaxBodySize: 1_000,
+		Auth:        APIKeyAuth("X-API-Key", map[string]string{"web": "web-key", "mobile": "web-key", "": "admin-key"}),
+	})
+
+	request := func(method, url, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("test-ingest", func(t *testing.T) {
+		body := `[
+			{"message": "page loaded", "source": "web", "level": "info"},
+			{"message": "click", "source": "web", "level": "debug", "fields": {"button": "buy"}},
+			{"message": "crash", "source": "web", "level": "error"}
+		]`
+
+		if rec := request(http.MethodPost, "/logs", "web-key", body); rec.Code != http.StatusCreated {
+			t.Fatalf("expected the logs to be created, got %v: %v", rec.Code, rec.Body.String())
+		}
+
+		if len(logger.Logs()) != 3 {
+			t.Fatalf("expected 3 stored logs, got %v", len(logger.Logs()))
+		}
+
+		if rec := request(http.MethodPost, "/logs", "wrong-key", body); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected an invalid key to be rejected, got %v", rec.Code)
+		}
+
+		if rec := request(http.MethodPost, "/logs", "web-key", `[{"message": "x", "source": "web", "level": "none"}]`); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected an invalid level to be rejected, got %v", rec.Code)
+		}
+
+		large := `[{"message": "` + strings.Repeat("x", 2_000) + `", "source": "web", "level": "info"}]`
+		if rec := request(http.MethodPost, "/logs", "web-key", large); rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected a large body to be rejected, got %v", rec.Code)
+		}
+	})
+
+	t.Run("test-query", func(t *testing.T) {
+		rec := request(http.MethodGet, "/logs?source=web&limit=10", "web-key", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected the logs to be returned, got %v: %v", rec.Code, rec.Body.String())
+		}
+
+		var page Page[Log]
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(page.Items) != 2 || page.NextCursor == "" {
+			t.Fatalf("expected the limit to be clamped to 2, got %v", len(page.Items))
+		}
+
+		// the max limit is used for the requests without the limit
+		rec = request(http.MethodGet, "/logs?source=web", "web-key", "")
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+
+		if rec.Code != http.StatusOK || len(page.Items) != 2 || page.NextCursor == "" {
+			t.Fatalf("expected the max limit of 2 logs without the limit, got %v: %v", rec.Code, len(page.Items))
+		}
+
+		if rec := request(http.MethodGet, "/logs", "web-key", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected the query of all sources to require the admin key, got %v", rec.Code)
+		}
+
+		// the hook receives all of the repeated sources
+		if rec := request(http.MethodGet, "/logs?source=web&source=mobile", "web-key", ""); rec.Code != http.StatusOK {
+			t.Fatalf("expected the key of both sources to be accepted, got %v", rec.Code)
+		}
+
+		if rec := request(http.MethodGet, "/logs?source=web&source=api", "web-key", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected the query of a source without the key to be rejected, got %v", rec.Code)
+		}
+
+		if rec := request(http.MethodGet, "/logs?limit=-1", "admin-key", ""); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected an invalid limit to be rejected, got %v", rec.Code)
+		}
+
+		if rec := request(http.MethodDelete, "/logs", "admin-key", ""); rec.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("expected the method to be rejected, got %v", rec.Code)
+		}
+	})
+}
+
+func TestLogStream(t *testing.T) {
+	broadcaster := NewLogBroadcaster(10)
+	memory := NewMemoryLogger(10, &LoggerSettings{DisableConsole: true})
+	logger := broadcaster.Wrap(memory).WithSource("api")
+
+	logger.Error("before the resume point")
+	logger.Warn("missed warning")
+	logger.Info("missed info")
+	logger.WithSource("web").Error("other source")
+
+	server := httptest.NewServer(LogStreamHandler(broadcaster, &LogStreamOptions{
+		Auth: APIKeyAuth("X-API-Key", map[string]string{"api": "api-key"}),
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	open := func(query, key, lastEventID string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("X-API-Key", key)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { res.Body.Close() })
+
+		return res
+	}
+
+	// read returns the ids of the events and the streamed logs
+	read := func(res *http.Response, n int) ([]string, []Log) {
+		t.Helper()
+
+		var ids []string
+		var logs []Log
+		scanner := bufio.NewScanner(res.Body)
+		for len(logs) < n && scanner.Scan() {
+			line := scanner.Text()
+
+			if id, ok := strings.CutPrefix(line, "id: "); ok {
+				ids = append(ids, id)
+			}
+
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var log Log
+				if err := json.Unmarshal([]byte(data), &log); err != nil {
+					t.Fatal(err)
+				}
+				logs = append(logs, log)
+			}
+		}
+
+		return ids, logs
+	}
+
+	t.Run("test-resume", func(t *testing.T) {
+		res := open("?source=api&min_level=warn", "api-key", "1")
+		if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("unexpected content type: %v", ct)
+		}
+
+		// published after the client is subscribed
+		logger.Debug("live debug")
+		logger.Fatal("live fatal")
+
+		ids, logs := read(res, 2)
+
+		var messages []string
+		for _, log := range logs {
+			messages = append(messages, log.Message)
+		}
+
+		if strings.Join(messages, ",") != "missed warning,live fatal" {
+			t.Fatalf("unexpected streamed logs: %v", messages)
+		}
+
+		if strings.Join(ids, ",") != "2,6" {
+			t.Fatalf("unexpected event ids: %v", ids)
+		}
+	})
+
+	t.Run("test-no-replay", func(t *testing.T) {
+		res := open("?source=api", "api-key", "")
+
+		logger.Warn("live warning")
+
+		_, logs := read(res, 1)
+		if len(logs) != 1 || logs[0].Message != "live warning" {
+			t.Fatalf("expected only the new log without the Last-Event-ID header, got %v", logs)
+		}
+
+		// the published log is the stored one
+		stored, err := memory.FindLogs(LogFilter{Message: "live warning"}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(stored) != 1 || stored[0].ID == "" || stored[0].ID != logs[0].ID || !stored[0].Timestamp.Equal(logs[0].Timestamp) {
+			t.Fatalf("expected the streamed log to match the stored one, got %v and %v", logs[0], stored)
+		}
+	})
+
+	t.Run("test-queries", func(t *testing.T) {
+		// the pagination and the aggregations of the wrapped logger are used
+		page, err := RequestLogsPage(logger, 2, "/logs?limit=2")
+		if err != nil || len(page.Items) != 2 || page.NextCursor == "" {
+			t.Fatalf("expected a page with a cursor, got %+v, %v", page, err)
+		}
+
+		aggs, err := RequestLogsAggregation(logger, "/logs?group_by=source&bucket=1h")
+		if err != nil || len(aggs) != 2 {
+			t.Fatalf("expected the counts of both sources, got %v, %v", aggs, err)
+		}
+	})
+
+	t.Run("test-auth", func(t *testing.T) {
+		if res := open("?source=web", "api-key", ""); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected the source without the key to be rejected, got %v", res.StatusCode)
+		}
+
+		if res := open("", "api-key", ""); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected the stream of all sources to be rejected, got %v", res.StatusCode)
+		}
+	})
+}
+
+func TestLogFilter(t *testing.T) {
+	logger := NewMemoryLogger(100, &LoggerSettings{DisableConsole: true})
+
+	logger.WithSource("api").WithEvent("auth").Info("User logged in", LogFields{"status": 200, "user": LogFields{"id": "u1"}})
+	logger.WithSource("api").WithEvent("auth").Warn("Token expires soon", LogFields{"status": 200})
+	logger.WithSource("web").WithEvent("page").Error("Page crashed", LogFields{"status": 500})
+	logger.WithSource("cron").Debug("job started")
+
+	find := func(query string) []Log {
+		t.Helper()
+
+		filter, err := parseLogsQuery("/logs?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logs, err := logger.FindLogs(*filter, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return logs
+	}
+
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"min_level=info", 3},
+		{"min_level=warn&max_level=4", 1},
+		{"level=debug", 1},
+		{"level=all", 4}, // invalid levels are ignored
+		{"source=api&source=web", 3},
+		{"event=auth&event=page&source=web", 1},
+		{"message=LOGGED", 1},
+		{"message_regex=^(Token|Page)", 2},
+		{"fields.status=200", 2},
+		{"fields.user.id=u1", 1},
+		{"from=" + time.Now().Add(-time.Minute).UTC().Format(time.RFC3339), 4},
+		{"to=" + time.Now().Add(-time.Minute).UTC().Format(time.RFC3339), 0},
+	}
+
+	for _, tt := range tests {
+		if logs := find(tt.query); len(logs) != tt.count {
+			t.Errorf("%v: expected %v logs, got %v", tt.query, tt.count, len(logs))
+		}
+	}
+
+	t.Run("test-invalid-queries", func(t *testing.T) {
+		for _, query := range []string{"min_level=verbose", "min_level=error&max_level=info", "message_regex=("} {
+			if _, err := parseLogsQuery("/logs?" + query); err == nil {
+				t.Errorf("%v: expected an error", query)
+			}
+		}
+	})
+
+	t.Run("test-mongo-query", func(t *testing.T) {
+		filter, err := parseLogsQuery("/logs?from=2024-01-01T00:00:00Z&min_level=warn&source=api&source=web&message=a.b&fields.status=200")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		query, err := logFilterQuery(*filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		timestamp := query["timestamp"].(bson.M)
+		if _, ok := timestamp["$lte"]; ok || timestamp["$gte"] == nil {
+			t.Fatalf("expected an open ended time range, got %v", timestamp)
+		}
+
+		if level := query["level"].(bson.M); level["$gte"] != WARN {
+			t.Fatalf("unexpected level query: %v", level)
+		}
+
+		if sources := query["source"].(bson.M)["$in"].([]string); len(sources) != 2 {
+			t.Fatalf("unexpected source query: %v", sources)
+		}
+
+		if msg := query["$and"].([]bson.M)[0]["message"].(bson.M); msg["$regex"] != `a\.b` || msg["$options"] != "i" {
+			t.Fatalf("unexpected message query: %v", msg)
+		}
+
+		if vals := query["fields.status"].(bson.M)["$in"].([]any); len(vals) != 2 || vals[1] != float64(200) {
+			t.Fatalf("unexpected fields query: %v", vals)
+		}
+	})
+
+	t.Run("test-mongo-executions-query", func(t *testing.T) {
+		// the executions use the same open ended time range as the logs
+		to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		query, err := execFilterQuery(CronExecFilter{TimeseriesFilter: TimeseriesFilter{To: to}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		initializedAt := query["initialized_at"].(bson.M)
+		if _, ok := initializedAt["$gte"]; ok || initializedAt["$lte"] != to {
+			t.Fatalf("expected an open ended time range, got %v", initializedAt)
+		}
+
+		if _, err := execFilterQuery(CronExecFilter{TimeseriesFilter: TimeseriesFilter{From: to.Add(time.Hour), To: to}}); err == nil {
+			t.Fatal("expected an error if from is after to")
+		}
+	})
+}
+
+func TestCursorPagination(t *testing.T) {
+	logger := NewMemoryLogger(100, &LoggerSettings{DisableConsole: true})
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	logs := make([]Log, 0, 9)
+	for i := range 7 {
+		logs = append(logs, Log{Timestamp: start.Add(time.Duration(i) * time.Minute), ID: fmt.Sprintf("log-%v", i), Level: INFO, Message: "log"})
+	}
+
+	// logs with the same timestamp are ordered by the id
+	ts := start.Add(time.Hour)
+	logs = append(logs,
+		Log{Timestamp: ts, ID: "b", Level: INFO, Message: "same time"},
+		Log{Timestamp: ts, ID: "a", Level: INFO, Message: "same time"},
+	)
+
+	if err := logger.WriteLogs(logs); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("too many pages")
+		}
+
+		page, err := RequestLogsPage(logger, 100, "/logs?limit=3&total=true&cursor="+cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if page.Total == nil || *page.Total != 9 {
+			t.Fatalf("expected the total of 9 logs, got %v", page.Total)
+		}
+
+		for _, log := range page.Items {
+			seen = append(seen, log.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	if len(seen) != 9 {
+		t.Fatalf("expected all 9 logs to be returned once, got %v", len(seen))
+	}
+
+	unique := make(map[string]bool)
+	for _, id := range seen {
+		unique[id] = true
+	}
+
+	if len(unique) != 9 {
+		t.Fatalf("expected unique logs in the pages, got %v", seen)
+	}
+
+	if strings.Join(seen, ",") != "b,a,log-6,log-5,log-4,log-3,log-2,log-1,log-0" {
+		t.Fatalf("expected the newest logs first, got %v", seen)
+	}
+
+	if _, err := parseLogsQuery("/logs?cursor=invalid"); err == nil {
+		t.Fatal("expected an error for an invalid cursor")
+	}
+}
+
+func TestAggregateLogs(t *testing.T) {
+	logger := NewMemoryLogger(100, &LoggerSettings{DisableConsole: true})
+
+	hour := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	logger.WriteLogs([]Log{
+		{Timestamp: hour.Add(5 * time.Minute), Level: ERROR, Source: "api", Message: "a"},
+		{Timestamp: hour.Add(50 * time.Minute), Level: ERROR, Source: "api", Message: "b"},
+		{Timestamp: hour.Add(55 * time.Minute), Level: ERROR, Source: "web", Message: "c"},
+		{Timestamp: hour.Add(65 * time.Minute), Level: FATAL, Source: "api", Message: "d"},
+		{Timestamp: hour.Add(70 * time.Minute), Level: INFO, Source: "api", Message: "e"},
+	})
+
+	aggs, err := RequestLogsAggregation(logger, "/logs?min_level=error&group_by=source&bucket=1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []LogAggregation{
+		{Bucket: hour, Source: "api", Count: 2},
+		{Bucket: hour, Source: "web", Count: 1},
+		{Bucket: hour.Add(time.Hour), Source: "api", Count: 1},
+	}
+
+	if len(aggs) != len(expected) {
+		t.Fatalf("expected %v aggregations, got %v", len(expected), aggs)
+	}
+
+	for i := range expected {
+		if !aggs[i].Bucket.Equal(expected[i].Bucket) || aggs[i].Source != expected[i].Source || aggs[i].Count != expected[i].Count || aggs[i].Level != 0 {
+			t.Fatalf("unexpected aggregation %v: %v, expected %v", i, aggs[i], expected[i])
+		}
+	}
+
+	aggs, err = logger.AggregateLogs(LogFilter{}, []string{"level"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(aggs) != 3 || aggs[0].Level != INFO || aggs[1].Count != 3 || !aggs[0].Bucket.IsZero() {
+		t.Fatalf("unexpected aggregation by level: %v", aggs)
+	}
+
+	for _, query := range []string{"group_by=message", "bucket=1us", "bucket=hour"} {
+		if _, err := RequestLogsAggregation(logger, "/logs?"+query); err == nil {
+			t.Errorf("%v: expected an error", query)
+		}
+	}
+}
+
+func TestExportLogs(t *testing.T) {
+	logger := NewMemoryLogger(3_000, &LoggerSettings{DisableConsole: true})
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	logs := make([]Log, 0, 2_500)
+	for i := range 2_500 {
+		logs = append(logs, Log{
+			Timestamp: ts.Add(time.Duration(i) * time.Second),
+			Level:     ERROR,
+			Source:    "api",
+			Message:   fmt.Sprintf("error %v", i),
+			Fields:    LogFields{"i": i, "user": LogFields{"id": "u1"}},
+		})
+	}
+	logger.WriteLogs(logs)
+	logger.WriteLogs([]Log{{Timestamp: ts.Add(-time.Second), Level: INFO, Message: "info, with comma", Fields: LogFields{"extra": true}}})
+
+	t.Run("test-ndjson", func(t *testing.T) {
+		var buf strings.Builder
+		if err := ExportLogs(context.Background(), logger, LogFilter{Level: func() *LogLevel { l := ERROR; return &l }()}, ExportNDJSON, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2_500 {
+			t.Fatalf("expected all 2500 error logs to be exported over the pages, got %v", len(lines))
+		}
+
+		var first Log
+		if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+			t.Fatal(err)
+		}
+
+		if first.Message != "error 2499" {
+			t.Fatalf("expected the newest log first, got %v", first.Message)
+		}
+	})
+
+	t.Run("test-csv", func(t *testing.T) {
+		var buf strings.Builder
+		if err := ExportLogs(context.Background(), logger, LogFilter{}, ExportCSV, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if lines[0] != "timestamp,level,source,event,event_id,message,fields.extra,fields.i,fields.user.id" {
+			t.Fatalf("unexpected header: %v", lines[0])
+		}
+
+		if len(lines) != 2_502 || lines[len(lines)-1] != `2023-12-31T23:59:59Z,info,,,,"info, with comma",true,,` {
+			t.Fatalf("unexpected rows: %v, last %v", len(lines), lines[len(lines)-1])
+		}
+
+		buf.Reset()
+		if err := ExportLogs(context.Background(), logger, LogFilter{Message: "error 1"}, ExportCSV, &buf, "message", "fields.user.id"); err != nil {
+			t.Fatal(err)
+		}
+
+		if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); lines[0] != "message,fields.user.id" || lines[1] != "error 1999,u1" {
+			t.Fatalf("unexpected selected columns: %v", lines[:2])
+		}
+	})
+
+	t.Run("test-canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := ExportLogs(ctx, logger, LogFilter{}, ExportNDJSON, io.Discard); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the export to be canceled, got %v", err)
+		}
+
+		if err := ExportLogs(context.Background(), logger, LogFilter{}, "xml", io.Discard); err == nil {
+			t.Fatal("expected an error for an invalid format")
+		}
+	})
+}
+
+func TestLogRetention(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	filters := levelPruneFilters(map[LogLevel]time.Duration{
+		ERROR: 90 * 24 * time.Hour,
+		DEBUG: 7 * 24 * time.Hour,
+		INFO:  0,
+	}, now)
+
+	if len(filters) != 2 || filters[0]["level"] != DEBUG || filters[1]["level"] != ERROR {
+		t.Fatalf("unexpected prune filters: %v", filters)
+	}
+
+	if cutoff := filters[0]["timestamp"].(bson.M)["$lt"]; cutoff != now.Add(-7*24*time.Hour) {
+		t.Fatalf("unexpected debug cutoff: %v", cutoff)
+	}
+
+	// the client does not connect until it is used
+	conn, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Disconnect(context.Background())
+
+	base := NewMongoLogger(conn.Database("test").Collection("logs"), nil)
+	logger := base.WithRetention(LogRetention{TTL: 90 * 24 * time.Hour, Levels: map[LogLevel]time.Duration{DEBUG: 7 * 24 * time.Hour}})
+
+	if base.Retention != nil || logger.Retention == nil {
+		t.Fatal("expected the retention to be set on the copy of the logger")
+	}
+
+	s := NewCronScheduler(cron.New(), "test")
+	if err := s.Register(logger.PruneJob("@daily")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateLogCollection(context.Background(), conn.Database("test"), "logs", LogCollectionOptions{TimeSeries: true, Capped: true}); err == nil {
+		t.Fatal("expected an error for a time-series and capped collection")
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	redaction := DefaultRedaction()
+	redaction.Redactors = append(redaction.Redactors, func(log *Log) {
+		if _, ok := log.Fields["ip"]; ok {
+			log.Fields["ip"] = "x.x.x.x"
+		}
+	})
+
+	settings := &LoggerSettings{DisableConsole: true, Redaction: redaction}
+
+	t.Run("test-log", func(t *testing.T) {
+		logger := NewMemoryLogger(10, settings)
+
+		user := LogFields{"email": "john@example.com", "Password": "hunter2", "tokens": []any{"Bearer abc.def"}}
+		headers := http.Header{"Authorization": {"Bearer abc"}, "Accept": {"*/*"}}
+		logger.With(LogFields{"api_key": "k1"}).Info("login of john@example.com with card 4111 1111 1111 1111", LogFields{"user": user, "headers": headers, "ip": "10.0.0.1"})
+
+		log := logger.Logs()[0]
+		if log.Message != "login of [REDACTED] with card [REDACTED]" {
+			t.Fatalf("unexpected message: %v", log.Message)
+		}
+
+		nested := log.Fields["user"].(LogFields)
+		if log.Fields["api_key"] != "[REDACTED]" || nested["Password"] != "[REDACTED]" || nested["email"] != "[REDACTED]" || nested["tokens"].([]any)[0] != "[REDACTED]" {
+			t.Fatalf("unexpected fields: %v", log.Fields)
+		}
+
+		if h := log.Fields["headers"].(http.Header); h.Get("Authorization") != "[REDACTED]" || h.Get("Accept") != "*/*" {
+			t.Fatalf("unexpected headers: %v", h)
+		}
+
+		if log.Fields["ip"] != "x.x.x.x" {
+			t.Fatalf("expected the custom redactor to be applied, got %v", log.Fields["ip"])
+		}
+
+		if user["Password"] != "hunter2" || headers.Get("Authorization") != "Bearer abc" {
+			t.Fatal("the fields of the caller should not be modified")
+		}
+	})
+
+	t.Run("test-card-numbers", func(t *testing.T) {
+		tests := []struct {
+			value    string
+			expected string
+		}{
+			{"card 4111-1111-1111-1111", "card [REDACTED]"},
+			{"card 5500 0000 0000 0004", "card [REDACTED]"},
+			// digits which do not pass the Luhn checksum are kept
+			{"timestamp 1704067200000000000", "timestamp 1704067200000000000"},
+			{"order 1234567890123456", "order 1234567890123456"},
+		}
+
+		for _, tt := range tests {
+			if v := redaction.redactString(tt.value); v != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, v)
+			}
+		}
+
+		// the validation is a part of the rule, so it is used with a custom pattern
+		custom := &Redaction{Rules: []RedactRule{{Pattern: regexp.MustCompile(`\b\d{16}\b`), Validate: RedactCardNumbers.Validate}}}
+		if v := custom.redactString("4111111111111111 1234567890123456"); v != "[REDACTED] 1234567890123456" {
+			t.Fatalf("expected only the valid card number to be redacted, got %q", v)
+		}
+	})
+
+	t.Run("test-multi", func(t *testing.T) {
+		sink := NewMemoryLogger(10, &LoggerSettings{DisableConsole: true})
+		logger := NewMultiLogger(settings, MultiSink{Logger: sink}).With(LogFields{"token": "t1"})
+
+		logger.Error("failed", LogFields{"secret": "s1"})
+		if log := sink.Logs()[0]; log.Fields["token"] != "[REDACTED]" || log.Fields["secret"] != "[REDACTED]" {
+			t.Fatalf("expected the sink to receive the redacted log, got %v", log.Fields)
+		}
+	})
+
+	t.Run("test-ingest", func(t *testing.T) {
+		logger := NewMemoryLogger(10, settings)
+
+		body := `[{"level":"info","message":"Authorization: bearer xyz","fields":{"request":{"token":"t1"}}}]`
+		w := httptest.NewRecorder()
+		LogsHandler(logger, nil).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/logs", strings.NewReader(body)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("unexpected status: %v %v", w.Code, w.Body.String())
+		}
+
+		log := logger.Logs()[0]
+		if log.Message != "Authorization: [REDACTED]" || log.Fields["request"].(map[string]any)["token"] != "[REDACTED]" {
+			t.Fatalf("expected the ingested log to be redacted, got %v %v", log.Message, log.Fields)
+		}
+	})
+}
+
+func TestConsoleFormats(t *testing.T) {
+	log := Log{
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:     WARN,
+		Source:    "api",
+		Event:     "login",
+		EventID:   "req-1",
+		Message:   "slow\nrequest",
+		Fields:    LogFields{"user": "john doe", "b": 2, "a": "x=y", "empty": ""},
+		Caller:    &LogCaller{File: "/src/app/api/users.go", Line: 42, Function: "main.login"},
+	}
+
+	format := func(settings *LoggerSettings) string { return log.String(NewConsoleLogger(settings)) }
+
+	t.Run("test-text", func(t *testing.T) {
+		expected := "2024-01-02 03:04:05  warn    api         login         slow\\nrequest   a=\"x=y\" b=2 empty=\"\" user=\"john doe\"\n"
+		for range 10 {
+			if out := format(&LoggerSettings{}); out != expected {
+				t.Fatalf("unexpected text output:\n%q\n%q", out, expected)
+			}
+		}
+
+		out := format(&LoggerSettings{ShowEventID: true, ShowCaller: true})
+		if err := stringIncludes(out, []string{"  [req-1] slow", "  api/users.go:42\n"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the captured caller is only printed with ShowCaller
+		if out := format(&LoggerSettings{CaptureCaller: true}); strings.Contains(out, "users.go") {
+			t.Fatalf("expected no caller in the output, got %q", out)
+		}
+	})
+
+	t.Run("test-logfmt", func(t *testing.T) {
+		expected := `time=2024-01-02T03:04:05Z level=warn source=api event=login event_id=req-1 msg="slow\nrequest" caller=api/users.go:42 a="x=y" b=2 empty="" user="john doe"` + "\n"
+		if out := format(&LoggerSettings{Format: ConsoleLogfmt, ShowEventID: true, ShowCaller: true}); out != expected {
+			t.Fatalf("unexpected logfmt output:\n%v\n%v", out, expected)
+		}
+	})
+
+	t.Run("test-json", func(t *testing.T) {
+		expected := `{"time":"2024-01-02T03:04:05Z","level":"warn","source":"api","event":"login","message":"slow\nrequest","fields":{"a":"x=y","b":2,"empty":"","user":"john doe"}}` + "\n"
+		if out := format(&LoggerSettings{Format: ConsoleJSON}); out != expected {
+			t.Fatalf("unexpected json output:\n%v\n%v", out, expected)
+		}
+	})
+
+	t.Run("test-color", func(t *testing.T) {
+		out := format(&LoggerSettings{Format: ConsoleColor})
+		if !strings.Contains(out, levelColors[WARN]+"warn  "+colorReset) {
+			t.Fatalf("expected the level to be colored, got %q", out)
+		}
+
+		// stdout of the tests is not a terminal
+		if out := format(&LoggerSettings{Format: ConsoleAuto}); strings.Contains(out, colorReset) {
+			t.Fatalf("expected no colors without a terminal, got %q", out)
+		}
+	})
+
+	t.Run("test-caller", func(t *testing.T) {
+		logger := NewMemoryLogger(10, &LoggerSettings{DisableConsole: true, ShowCaller: true})
+		logger.WithSource("api").Info("with caller")
+
+		caller := logger.Logs()[0].Caller
+		if caller == nil || !strings.HasSuffix(caller.File, "main_test.go") || !strings.Contains(caller.Function, "TestConsoleFormats") {
+			t.Fatalf("expected the caller to be the test, got %+v", caller)
+		}
+	})
+}
+
+func TestLogCaller(t *testing.T) {
+	sink := NewMemoryLogger(10, &LoggerSettings{DisableConsole: true, CaptureCaller: true, CaptureStack: true})
+	logger := NewMultiLogger(nil, MultiSink{Logger: sink}).WithSource("api")
+
+	logger.Info("info log")
+	logger.Error("failed to register execution")
+
+	logs := sink.Logs()
+	info, failed := logs[0], logs[1]
+
+	if info.Caller == nil || !strings.HasSuffix(info.Caller.File, "main_test.go") || !strings.Contains(info.Caller.Function, "TestLogCaller") {
+		t.Fatalf("expected the caller to be the test, got %+v", info.Caller)
+	}
+
+	if info.Stack != "" {
+		t.Fatalf("expected no stack for the info log, got %v", info.Stack)
+	}
+
+	if failed.Caller.Line != info.Caller.Line+1 || !strings.HasPrefix(failed.Stack, failed.Caller.Function+"\n\t") {
+		t.Fatalf("unexpected caller or stack of the error log: %+v\n%v", failed.Caller, failed.Stack)
+	}
+
+	t.Run("test-slog", func(t *testing.T) {
+		sink := NewMemoryLogger(10, &LoggerSettings{DisableConsole: true, CaptureCaller: true, CaptureStack: true})
+		slog.New(NewSlogHandler(sink, nil)).Error("slog log")
+
+		log := sink.Logs()[0]
+		if log.Caller == nil || !strings.HasSuffix(log.Caller.File, "main_test.go") || !strings.Contains(log.Caller.Function, "TestLogCaller") {
+			t.Fatalf("expected the caller to be the test, got %+v", log.Caller)
+		}
+
+		if !strings.HasPrefix(log.Stack, log.Caller.Function+"\n\t") {
+			t.Fatalf("expected the stack to start at the test, got %v", log.Stack)
+		}
+	})
+
+	t.Run("test-wrapper-chain", func(t *testing.T) {
+		sink := NewMemoryLogger(10, &LoggerSettings{DisableConsole: true, CaptureCaller: true, CaptureStack: true})
+
+		// more logger frames than the initial buffer of the program counters
+		var wrapped Logger = sink
+		for range 10 {
+			wrapped = NewMultiLogger(nil, MultiSink{Logger: wrapped})
+		}
+
+		slog.New(NewSlogHandler(NewLogBroadcaster(10).Wrap(wrapped), nil)).Error("wrapped log")
+
+		log := sink.Logs()[0]
+		if log.Caller == nil || !strings.Contains(log.Caller.Function, "TestLogCaller") {
+			t.Fatalf("expected the caller to be the test, got %+v", log.Caller)
+		}
+
+		if !strings.HasPrefix(log.Stack, log.Caller.Function+"\n\t") {
+			t.Fatalf("expected the stack to start at the test, got %v", log.Stack)
+		}
+	})
+
+	t.Run("test-filter", func(t *testing.T) {
+		filter, err := parseLogsQuery("/logs?caller=TestLogCaller")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if found, err := sink.FindLogs(*filter, 10); err != nil || len(found) != 2 {
+			t.Fatalf("expected the logs of the caller, got %v, %v", len(found), err)
+		}
+
+		if found, _ := sink.FindLogs(LogFilter{Caller: "other.go"}, 10); len(found) != 0 {
+			t.Fatalf("expected no logs for another caller, got %v", len(found))
+		}
+	})
+
+	t.Run("test-mongo-document", func(t *testing.T) {
+		doc := logDocument(failed)
+		if doc["caller"] != failed.Caller || doc["stack"] != failed.Stack {
+			t.Fatalf("expected the caller and the stack to be stored, got %v", doc)
+		}
+
+		query, err := logFilterQuery(LogFilter{Caller: "users.go"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		caller := bson.M{"$regex": `users\.go`}
+		expected := []bson.M{{"$or": []bson.M{{"caller.file": caller}, {"caller.function": caller}}}}
+		if fmt.Sprint(query["$and"]) != fmt.Sprint(expected) {
+			t.Fatalf("unexpected caller query: %v", query)
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package syro
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MemoryLogger is a Logger which keeps the last logs in a ring buffer. The
+// FindLogs method uses the same filter rules as the MongoLogger, so it
+// can be used instead of it in local development and in tests.
+type MemoryLogger struct {
+	Settings *LoggerSettings
+	Source   string
+	Event    string
+	EventID  string
+	Fields   LogFields     // Default fields which are added to every log
+	buf      *memoryBuffer // shared by the child loggers
+}
+
+var _ Logger = (*MemoryLogger)(nil)
+
+const (
+	defaultMemoryLogSize   = 1_000
+	memorySubscriberBuffer = 100
+)
+
+// NewMemoryLogger creates a logger which keeps the last size logs. If size
+// is not positive, 1000 logs are kept.
+func NewMemoryLogger(size int, settings *LoggerSettings) *MemoryLogger {
+	if size <= 0 {
+		size = defaultMemoryLogSize
+	}
+
+	return &MemoryLogger{
+		Settings: settings,
+		buf:      &memoryBuffer{logs: make([]Log, size), subs: make(map[chan Log]struct{})},
+	}
+}
+
+func (lg *MemoryLogger) GetProps() LoggerProps {
+	return LoggerProps{
+		Settings: lg.Settings,
+		Source:   lg.Source,
+		Event:    lg.Event,
+		EventID:  lg.EventID,
+		Fields:   lg.Fields,
+	}
+}
+
+func (lg *MemoryLogger) Name() string { return "memory" }
+
+func (lg *MemoryLogger) GetTableName() string { return "" }
+
+func (lg *MemoryLogger) log(level LogLevel, msg string, lf ...LogFields) error {
+	_, _, err := lg.record(level, msg, lf...)
+	return err
+}
+
+func (lg *MemoryLogger) record(level LogLevel, msg string, lf ...LogFields) (Log, bool, error) {
+	if !lg.Settings.Enabled(level, lg.Source) {
+		return Log{}, false, nil
+	}
+
+	log := newLog(lg.Settings, level, msg, lg.Source, lg.Event, lg.EventID, mergeFields(lg.Fields, lf...))
+	log.ID = newRandomID()
+
+	lg.buf.add(log)
+
+	if lg.Settings == nil || !lg.Settings.DisableConsole {
+		fmt.Print(log.String(lg))
+	}
+
+	return log, true, nil
+}
+
+func (lg *MemoryLogger) clone() *MemoryLogger {
+	c := *lg
+	return &c
+}
+
+func (lg *MemoryLogger) WithSource(v string) Logger {
+	c := lg.clone()
+	c.Source = v
+	return c
+}
+
+func (lg *MemoryLogger) WithEvent(v string) Logger {
+	c := lg.clone()
+	c.Event = v
+	return c
+}
+
+func (lg *MemoryLogger) WithEventID(v string) Logger {
+	c := lg.clone()
+	c.EventID = v
+	return c
+}
+
+func (lg *MemoryLogger) With(fields LogFields) Logger {
+	c := lg.clone()
+	c.Fields = mergeFields(lg.Fields, fields)
+	return c
+}
+
+func (lg *MemoryLogger) Debug(msg string, lf ...LogFields) error { return lg.log(DEBUG, msg, lf...) }
+func (lg *MemoryLogger) Trace(msg string, lf ...LogFields) error { return lg.log(TRACE, msg, lf...) }
+func (lg *MemoryLogger) Error(msg string, lf ...LogFields) error { return lg.log(ERROR, msg, lf...) }
+func (lg *MemoryLogger) Info(msg string, lf ...LogFields) error  { return lg.log(INFO, msg, lf...) }
+func (lg *MemoryLogger) Warn(msg string, lf ...LogFields) error  { return lg.log(WARN, msg, lf...) }
+func (lg *MemoryLogger) Fatal(msg string, lf ...LogFields) error { return lg.log(FATAL, msg, lf...) }
+
+// WriteLogs adds the logs to the buffer. The logs are not printed to the console.
+func (lg *MemoryLogger) WriteLogs(logs []Log) error {
+	for _, log := range enabledLogs(lg.Settings, logs) {
+		if log.ID == "" {
+			log.ID = newRandomID()
+		}
+
+		lg.buf.add(log)
+	}
+
+	return nil
+}
+
+// LogExists checks if a log which matches the filter exists. The filter
+// must have a LogFilter type.
+func (lg *MemoryLogger) LogExists(filter any) (bool, error) {
+	f, ok := filter.(LogFilter)
+	if !ok {
+		return false, errors.New("filter must have a LogFilter type")
+	}
+
+	f.Limit, f.Skip = 1, 0
+	logs, err := lg.FindLogs(f, 1)
+	return len(logs) > 0, err
+}
+
+// FindLogs returns the logs in the buffer which match the filter, sorted by
+// time in descending order.
+func (lg *MemoryLogger) FindLogs(filter LogFilter, maxLimit int64) ([]Log, error) {
+	page, err := lg.FindLogsPage(filter, maxLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+// FindLogsPage returns a page of the logs which match the filter.
+func (lg *MemoryLogger) FindLogsPage(filter LogFilter, maxLimit int64) (*Page[Log], error) {
+	matches, err := filter.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]Log, 0)
+	for _, log := range lg.buf.all() {
+		if matches(&log) {
+			logs = append(logs, log)
+		}
+	}
+
+	return pageLogs(logs, filter.TimeseriesFilter, maxLimit)
+}
+
+// Logs returns all of the logs in the buffer, from the oldest to the newest.
+func (lg *MemoryLogger) Logs() []Log { return lg.buf.all() }
+
+// Reset removes all of the logs from the buffer.
+func (lg *MemoryLogger) Reset() { lg.buf.reset() }
+
+// Subscribe returns a channel which receives the new logs, for live tailing.
+// If the subscriber is too slow and the channel is full, the logs are
+// dropped. The returned func stops the subscription and closes the channel.
+func (lg *MemoryLogger) Subscribe() (<-chan Log, func()) {
+	return lg.buf.subscribe()
+}
+
+// memoryBuffer is a fixed size ring buffer of logs.
+type memoryBuffer struct {
+	mu   sync.RWMutex
+	logs []Log
+	next int // index where the next log is written
+	full bool
+	subs map[chan Log]struct{}
+}
+
+func (b *memoryBuffer) add(log Log) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logs[b.next] = log
+	b.next = (b.next + 1) % len(b.logs)
+	if b.next == 0 {
+		b.full = true
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- log:
+		default:
+		}
+	}
+}
+
+func (b *memoryBuffer) all() []Log {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if !b.full {
+		return append([]Log{}, b.logs[:b.next]...)
+	}
+
+	logs := make([]Log, 0, len(b.logs))
+	logs = append(logs, b.logs[b.next:]...)
+	return append(logs, b.logs[:b.next]...)
+}
+
+func (b *memoryBuffer) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	clear(b.logs)
+	b.next, b.full = 0, false
+}
+
+func (b *memoryBuffer) subscribe() (<-chan Log, func()) {
+	ch := make(chan Log, memorySubscriberBuffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			close(ch)
+			b.mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package syro
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OverflowPolicy decides what happens with a new log if the queue of the
+// async logger is full.
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // wait until there is space in the queue
+	OverflowDropOldest                       // remove the oldest log from the queue
+	OverflowDropNewest                       // drop the new log
+)
+
+// AsyncOptions are the settings of the async mode of the MongoLogger.
+type AsyncOptions struct {
+	QueueSize      int            // Max number of logs in the queue, defaults to 10_000
+	BatchSize      int            // Max number of logs written with a single InsertMany, defaults to 500 (at most the QueueSize)
+	FlushInterval  time.Duration  // How often the queue is written if the batch is not full, defaults to 1s
+	OverflowPolicy OverflowPolicy // What happens if the queue is full, defaults to OverflowBlock
+}
+
+// AsyncStats are the counters of the async mode of the MongoLogger.
+type AsyncStats struct {
+	Written uint64 `json:"written"` // Number of logs which were written
+	Dropped uint64 `json:"dropped"` // Number of logs which were dropped because the queue was full
+	Failed  uint64 `json:"failed"`  // Number of logs which failed to be written
+}
+
+var errAsyncWriterClosed = errors.New("async log writer is closed")
+
+// WithAsync returns a copy of the logger with the async mode enabled. Logs
+// are added to a bounded queue and written in batches by a background
+// writer, which is owned by the returned logger and shared by its child
+// loggers. Close should be called before the app exits, so that the
+// queued logs are not lost. The receiver is not modified.
+func (lg *MongoLogger) WithAsync(opts AsyncOptions) *MongoLogger {
+	c := lg.clone()
+
+	coll := lg.Coll
+	c.async = newAsyncLogWriter(func(ctx context.Context, docs []any) (int, error) {
+		_, err := coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+		if err == nil {
+			return len(docs), nil
+		}
+
+		// with an unordered insert, only the documents with write errors fail
+		var bulkErr mongo.BulkWriteException
+		if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+			return len(docs) - len(bulkErr.WriteErrors), err
+		}
+
+		return 0, err
+	}, opts)
+
+	return c
+}
+
+// Flush writes all of the queued logs. Does nothing if the async mode is not enabled.
+func (lg *MongoLogger) Flush(ctx context.Context) error {
+	if lg.async == nil {
+		return nil
+	}
+
+	return lg.async.flush(ctx)
+}
+
+// Close writes all of the queued logs and stops the background writer. Logs
+// which are created after Close return an error.
+func (lg *MongoLogger) Close(ctx context.Context) error {
+	if lg.async == nil {
+		return nil
+	}
+
+	return lg.async.close(ctx)
+}
+
+// Stats returns the counters of the async mode.
+func (lg *MongoLogger) Stats() AsyncStats {
+	if lg.async == nil {
+		return AsyncStats{}
+	}
+
+	return lg.async.stats()
+}
+
+// asyncLogWriter is a bounded queue of documents which are written in
+// batches by a background goroutine.
+type asyncLogWriter struct {
+	insert func(ctx context.Context, docs []any) (int, error)
+	opts   AsyncOptions
+
+	mu      sync.Mutex
+	notFull *sync.Cond
+	queue   []any
+	closed  bool
+
+	wake     chan struct{}
+	flushReq chan chan error
+	done     chan struct{}
+	stopped  chan struct{}
+	closeErr error // error of the final write, set before stopped is closed
+
+	written atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+func newAsyncLogWriter(insert func(ctx context.Context, docs []any) (int, error), opts AsyncOptions) *asyncLogWriter {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10_000
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	// a batch larger than the queue would never be full
+	if opts.BatchSize > opts.QueueSize {
+		opts.BatchSize = opts.QueueSize
+	}
+
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+
+	w := &asyncLogWriter{
+		insert:   insert,
+		opts:     opts,
+		wake:     make(chan struct{}, 1),
+		flushReq: make(chan chan error),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	w.notFull = sync.NewCond(&w.mu)
+
+	go w.loop()
+	return w
+}
+
+// enqueue adds the document to the queue, applying the overflow policy if
+// the queue is full.
+func (w *asyncLogWriter) enqueue(doc any) error {
+	w.mu.Lock()
+
+	for !w.closed && len(w.queue) >= w.opts.QueueSize {
+		switch w.opts.OverflowPolicy {
+		case OverflowDropNewest:
+			w.mu.Unlock()
+			w.dropped.Add(1)
+			return nil
+		case OverflowDropOldest:
+			w.queue = w.queue[1:]
+			w.dropped.Add(1)
+		default:
+			w.notFull.Wait()
+		}
+	}
+
+	if w.closed {
+		w.mu.Unlock()
+		return errAsyncWriterClosed
+	}
+
+	w.queue = append(w.queue, doc)
+	full := len(w.queue) >= w.opts.BatchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (w *asyncLogWriter) loop() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.wake:
+			w.write(true)
+		case <-ticker.C:
+			w.write(false)
+		case reply := <-w.flushReq:
+			reply <- w.write(false)
+		case <-w.done:
+			w.closeErr = w.write(false)
+			return
+		}
+	}
+}
+
+// write inserts the queued documents in batches. If onlyFull is true, only
+// full batches are written.
+func (w *asyncLogWriter) write(onlyFull bool) error {
+	eg := NewErrGroup(ErrGroupProps{ID: "failed to write logs"})
+
+	for {
+		w.mu.Lock()
+		n := min(len(w.queue), w.opts.BatchSize)
+		if n == 0 || (onlyFull && n < w.opts.BatchSize) {
+			w.mu.Unlock()
+			return eg.ToErr()
+		}
+
+		batch := make([]any, n)
+		copy(batch, w.queue[:n])
+		w.queue = w.queue[n:]
+		w.notFull.Broadcast()
+		w.mu.Unlock()
+
+		inserted, err := w.insert(context.Background(), batch)
+		w.written.Add(uint64(inserted))
+		if err != nil {
+			w.failed.Add(uint64(len(batch) - inserted))
+			eg.Add(err)
+		}
+	}
+}
+
+func (w *asyncLogWriter) flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+
+	select {
+	case w.flushReq <- reply:
+	case <-w.stopped:
+		return errAsyncWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *asyncLogWriter) close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.done)
+		w.notFull.Broadcast()
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.stopped:
+		return w.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *asyncLogWriter) stats() AsyncStats {
+	return AsyncStats{
+		Written: w.written.Load(),
+		Dropped: w.dropped.Load(),
+		Failed:  w.failed.Load(),
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -13,23 +15,43 @@ import (
 )
 
 type MongoLogger struct {
-	Coll     *mongo.Collection
-	Settings *LoggerSettings
-	Source   string
-	Event    string
-	EventID  string
+	Coll      *mongo.Collection
+	Settings  *LoggerSettings
+	Source    string
+	Event     string
+	EventID   string
+	Fields    LogFields       // Default fields which are added to every log
+	Retention *LogRetention   // Optional. Retention of the logs, see WithRetention
+	async     *asyncLogWriter // optional background writer, see WithAsync, shared by the child loggers
 }
 
 func NewMongoLogger(coll *mongo.Collection, settings *LoggerSettings) *MongoLogger {
 	return &MongoLogger{Coll: coll, Settings: settings}
 }
 
+// CreateIndexes creates the indexes of the logs. If the retention with the
+// TTL is specified, the TTL index on the timestamp is created as well.
 func (lg *MongoLogger) CreateIndexes() error {
-	return newMongoIndexes().
+	indexes := newMongoIndexes().
 		Add("timestamp", "level").
+		Add("timestamp", "_id"). // used by the cursor pagination
 		Add("source", "event").
-		Add("event_id").
-		Create(lg.Coll)
+		Add("event_id")
+
+	// used by the prune of the levels
+	if lg.Retention != nil && len(lg.Retention.Levels) > 0 {
+		indexes.Add("level", "timestamp")
+	}
+
+	if err := indexes.Create(lg.Coll); err != nil {
+		return err
+	}
+
+	if lg.Retention != nil && lg.Retention.TTL > 0 {
+		return lg.createTTLIndex(lg.Retention.TTL)
+	}
+
+	return nil
 }
 
 func (lg *MongoLogger) GetTableName() string {
@@ -42,6 +64,7 @@ func (lg *MongoLogger) GetProps() LoggerProps {
 		Source:   lg.Source,
 		Event:    lg.Event,
 		EventID:  lg.EventID,
+		Fields:   lg.Fields,
 	}
 }
 
@@ -49,24 +72,71 @@ func (lg *MongoLogger) Name() string {
 	return "mongo"
 }
 
+// clone returns a shallow copy of the logger, so that the With* methods
+// do not modify the logger which may be shared between goroutines.
+func (lg *MongoLogger) clone() *MongoLogger {
+	c := *lg
+	return &c
+}
+
 func (lg *MongoLogger) WithSource(v string) Logger {
-	lg.Source = v
-	return lg
+	c := lg.clone()
+	c.Source = v
+	return c
 }
 
 func (lg *MongoLogger) WithEvent(v string) Logger {
-	lg.Event = v
-	return lg
+	c := lg.clone()
+	c.Event = v
+	return c
 }
 
 func (lg *MongoLogger) WithEventID(v string) Logger {
-	lg.EventID = v
-	return lg
+	c := lg.clone()
+	c.EventID = v
+	return c
+}
+
+func (lg *MongoLogger) With(fields LogFields) Logger {
+	c := lg.clone()
+	c.Fields = mergeFields(lg.Fields, fields)
+	return c
 }
 
 func (lg *MongoLogger) log(level LogLevel, msg string, lf ...LogFields) error {
-	log := NewLog(level, msg, lg.Source, lg.Event, lg.EventID, lf...)
+	_, _, err := lg.record(level, msg, lf...)
+	return err
+}
+
+// record inserts the log with an id which is created beforehand, so that
+// the returned log matches the stored document.
+func (lg *MongoLogger) record(level LogLevel, msg string, lf ...LogFields) (Log, bool, error) {
+	if !lg.Settings.Enabled(level, lg.Source) {
+		return Log{}, false, nil
+	}
+
+	log := newLog(lg.Settings, level, msg, lg.Source, lg.Event, lg.EventID, mergeFields(lg.Fields, lf...))
+	log.ID = primitive.NewObjectID().Hex()
+
+	set := logDocument(log)
+
+	var err error
+	if lg.async != nil {
+		err = lg.async.enqueue(set)
+	} else {
+		_, err = lg.Coll.InsertOne(context.Background(), set)
+	}
+
+	if lg.Settings == nil || !lg.Settings.DisableConsole {
+		fmt.Print(log.String(lg))
+	}
 
+	return log, true, err
+}
+
+// logDocument returns the document of the log which is inserted in the
+// collection.
+func logDocument(log Log) bson.M {
 	// a custom set is defined because just using an InsertOne on the log
 	// struct will break the _id field. omitempty does not work, if
 	// the field has a string type.
@@ -77,6 +147,13 @@ func (lg *MongoLogger) log(level LogLevel, msg string, lf ...LogFields) error {
 		"message":   log.Message,
 	}
 
+	// ids of the logs created by the loggers are valid object ids
+	if oid, err := primitive.ObjectIDFromHex(log.ID); err == nil {
+		set["_id"] = oid
+	} else if log.ID != "" {
+		set["_id"] = log.ID
+	}
+
 	if log.Source != "" {
 		set["source"] = log.Source
 	}
@@ -93,8 +170,40 @@ func (lg *MongoLogger) log(level LogLevel, msg string, lf ...LogFields) error {
 		set["fields"] = log.Fields
 	}
 
-	_, err := lg.Coll.InsertOne(context.Background(), set)
-	fmt.Print(log.String(lg))
+	if log.Caller != nil {
+		set["caller"] = log.Caller
+	}
+
+	if log.Stack != "" {
+		set["stack"] = log.Stack
+	}
+
+	return set
+}
+
+// WriteLogs inserts the logs with a single InsertMany, or adds them to the
+// queue if the async mode is enabled. The logs are not printed to the console.
+func (lg *MongoLogger) WriteLogs(logs []Log) error {
+	logs = enabledLogs(lg.Settings, logs)
+	if len(logs) == 0 {
+		return nil
+	}
+
+	docs := make([]any, len(logs))
+	for i, log := range logs {
+		docs[i] = logDocument(log)
+	}
+
+	if lg.async != nil {
+		eg := NewErrGroup(ErrGroupProps{ID: "failed to write logs"})
+		for _, doc := range docs {
+			eg.Add(lg.async.enqueue(doc))
+		}
+
+		return eg.ToErr()
+	}
+
+	_, err := lg.Coll.InsertMany(context.Background(), docs, options.InsertMany().SetOrdered(false))
 	return err
 }
 
@@ -123,48 +232,122 @@ func (lg *MongoLogger) Fatal(msg string, lf ...LogFields) error { return lg.log(
 
 // FindLogs returns logs that match the filter
 func (lg *MongoLogger) FindLogs(filter LogFilter, maxLimit int64) ([]Log, error) {
+	page, err := lg.FindLogsPage(filter, maxLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+// FindLogsPage returns a page of the logs that match the filter. The cursor
+// uses the timestamp and the _id of the logs, so the page is found with
+// the index instead of skipping the documents.
+func (lg *MongoLogger) FindLogsPage(filter LogFilter, maxLimit int64) (*Page[Log], error) {
+	queryFilter, err := logFilterQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return mongoFindPage(lg.Coll, queryFilter, filter.TimeseriesFilter, maxLimit, "timestamp", func(log Log) (time.Time, string) {
+		return log.Timestamp, log.ID
+	})
+}
+
+// logFilterQuery converts the filter to the mongo query.
+func logFilterQuery(filter LogFilter) (bson.M, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
 
 	queryFilter := bson.M{}
 
-	// if the from and to fields are not zero, add them to the query filter
-	if !filter.From.IsZero() && !filter.To.IsZero() {
-		if filter.From.After(filter.To) {
-			return nil, errors.New("'from' date cannot be after 'to' date")
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		timestamp := bson.M{}
+		if !filter.From.IsZero() {
+			timestamp["$gte"] = filter.From
 		}
 
-		queryFilter["timestamp"] = bson.M{"$gte": filter.From, "$lte": filter.To}
+		if !filter.To.IsZero() {
+			timestamp["$lte"] = filter.To
+		}
+
+		queryFilter["timestamp"] = timestamp
 	}
 
-	level := filter.Level
-	if level != nil && *level >= TRACE && *level <= FATAL {
-		queryFilter["level"] = *level
+	level := bson.M{}
+	if filter.MinLevel != nil {
+		level["$gte"] = *filter.MinLevel
 	}
 
-	if filter.Source != "" {
-		queryFilter["source"] = filter.Source
+	if filter.MaxLevel != nil {
+		level["$lte"] = *filter.MaxLevel
 	}
 
-	if filter.Event != "" {
-		queryFilter["event"] = filter.Event
+	if l := filter.Level; l != nil && *l >= TRACE && *l <= FATAL {
+		level["$eq"] = *l
+	}
+
+	if len(level) > 0 {
+		queryFilter["level"] = level
+	}
+
+	if sources := filter.sources(); len(sources) > 0 {
+		queryFilter["source"] = bson.M{"$in": sources}
+	}
+
+	if events := filter.events(); len(events) > 0 {
+		queryFilter["event"] = bson.M{"$in": events}
 	}
 
 	if filter.EventID != "" {
 		queryFilter["event_id"] = filter.EventID
 	}
 
-	userLimit := filter.TimeseriesFilter.Limit
-	if userLimit > maxLimit {
-		userLimit = maxLimit
+	var and []bson.M
+	if filter.Message != "" {
+		and = append(and, bson.M{"message": bson.M{"$regex": regexp.QuoteMeta(filter.Message), "$options": "i"}})
 	}
 
-	opts := options.Find().
-		SetSort(bson.D{{Key: "timestamp", Value: -1}}). // sort by time field in descending order
-		SetLimit(userLimit).
-		SetSkip(filter.TimeseriesFilter.Skip)
+	if filter.MessageRegex != "" {
+		and = append(and, bson.M{"message": bson.M{"$regex": filter.MessageRegex}})
+	}
 
-	var docs []Log
-	err := mongoGetDocuments(lg.Coll, queryFilter, opts, &docs)
-	return docs, err
+	if filter.Caller != "" {
+		caller := bson.M{"$regex": regexp.QuoteMeta(filter.Caller)}
+		and = append(and, bson.M{"$or": []bson.M{{"caller.file": caller}, {"caller.function": caller}}})
+	}
+
+	if len(and) > 0 {
+		queryFilter["$and"] = and
+	}
+
+	for key, v := range filter.Fields {
+		queryFilter["fields."+key] = bson.M{"$in": fieldQueryValues(v)}
+	}
+
+	return queryFilter, nil
+}
+
+// fieldQueryValues returns the values which match the value of the field
+// filter. Values from the URL are strings, so the number and bool
+// versions of the value are matched as well (and the other way around).
+func fieldQueryValues(v any) []any {
+	s, ok := v.(string)
+	if !ok {
+		return []any{v, fmt.Sprint(v)}
+	}
+
+	vals := []any{s}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		vals = append(vals, n)
+	}
+
+	if s == "true" || s == "false" {
+		vals = append(vals, s == "true")
+	}
+
+	return vals
 }
 
 // --------------- Cron Job Logic ---------------
@@ -175,6 +358,8 @@ type MongoCronStorage struct {
 	cronHistoryColl *mongo.Collection
 }
 
+var _ CronStorage = (*MongoCronStorage)(nil)
+
 func NewMongoCronStorage(cronListColl, cronHistoryColl *mongo.Collection) (*MongoCronStorage, error) {
 	if cronListColl == nil || cronHistoryColl == nil {
 		return nil, fmt.Errorf("collections cannot be nil")
@@ -188,22 +373,77 @@ func NewMongoCronStorage(cronListColl, cronHistoryColl *mongo.Collection) (*Mong
 
 func (m *MongoCronStorage) CreateIndexes() error {
 	// Create indexes for the collections
-	if err := newMongoIndexes().Add("source", "name").Add("status").Create(m.cronListColl); err != nil {
+	if err := newMongoIndexes().Add("source", "name").Add("status").Add("tags").Add("updated_at").Create(m.cronListColl); err != nil {
 		return err
 	}
 
 	// Create indexes for the collections
-	if err := newMongoIndexes().Add("source", "name").Add("initialized_at").Create(m.cronHistoryColl); err != nil {
+	if err := newMongoIndexes().Add("source", "name").Add("initialized_at", "_id").Create(m.cronHistoryColl); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// TODO: refactor so that filter is a variadic parameter
-func (m *MongoCronStorage) FindCronJobs() ([]CronJob, error) {
+// FindCronJobs returns the registered jobs that match the filter
+func (m *MongoCronStorage) FindCronJobs(filter CronJobFilter, maxLimit int64) ([]CronJob, error) {
+	queryFilter := bson.M{}
+
+	from, to := filter.From, filter.To
+
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return nil, errors.New("from date cannot be after to date")
+	}
+
+	if !from.IsZero() || !to.IsZero() {
+		updatedAt := bson.M{}
+		if !from.IsZero() {
+			updatedAt["$gte"] = from
+		}
+
+		if !to.IsZero() {
+			updatedAt["$lte"] = to
+		}
+
+		queryFilter["updated_at"] = updatedAt
+	}
+
+	if filter.Source != "" {
+		queryFilter["source"] = filter.Source
+	}
+
+	if filter.Name != "" {
+		if _, err := regexp.Compile(filter.Name); err != nil {
+			return nil, fmt.Errorf("invalid name pattern: %v", err)
+		}
+
+		queryFilter["name"] = bson.M{"$regex": filter.Name}
+	}
+
+	if filter.Status != "" {
+		queryFilter["status"] = filter.Status
+	}
+
+	if len(filter.Tags) > 0 {
+		queryFilter["tags"] = bson.M{"$all": filter.Tags}
+	}
+
+	if filter.ExitWithErr != nil {
+		queryFilter["exit_with_err"] = *filter.ExitWithErr
+	}
+
+	userLimit := filter.TimeseriesFilter.Limit
+	if userLimit > maxLimit {
+		userLimit = maxLimit
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+		SetLimit(userLimit).
+		SetSkip(filter.TimeseriesFilter.Skip)
+
 	var docs []CronJob
-	err := mongoGetDocuments(m.cronListColl, bson.M{}, nil, &docs)
+	err := mongoGetDocuments(m.cronListColl, queryFilter, opts, &docs)
 	return docs, err
 }
 
@@ -219,7 +459,7 @@ func (m *MongoCronStorage) SetJobsToInactive(source string) error {
 // and the job name. If the job does not exist, set the created_at
 // field to the current time. If the job already exists,
 // update the updated_at field to the current time.
-func (m *MongoCronStorage) RegisterJob(source, name, sched, descr string, status JobStatus, fnErr error) error {
+func (m *MongoCronStorage) RegisterJob(source, name, sched, descr string, tags []string, status JobStatus, fnErr error) error {
 	filter := bson.M{
 		"source": source,
 		"name":   name,
@@ -232,6 +472,12 @@ func (m *MongoCronStorage) RegisterJob(source, name, sched, descr string, status
 		"updated_at": time.Now().UTC(),
 	}
 
+	if tags != nil {
+		set["tags"] = tags
+	} else {
+		set["tags"] = []string{}
+	}
+
 	if fnErr != nil {
 		set["exit_with_err"] = true
 		set["error"] = fnErr.Error()
@@ -264,17 +510,48 @@ func (m *MongoCronStorage) RegisterExecution(ex *CronExecLog) error {
 
 // FindExecutions returns a list of executions based on the filter
 func (m *MongoCronStorage) FindExecutions(filter CronExecFilter, maxLimit int64) ([]CronExecLog, error) {
+	page, err := m.FindExecutionsPage(filter, maxLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+// FindExecutionsPage returns a page of the executions based on the filter.
+func (m *MongoCronStorage) FindExecutionsPage(filter CronExecFilter, maxLimit int64) (*Page[CronExecLog], error) {
+	queryFilter, err := execFilterQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return mongoFindPage(m.cronHistoryColl, queryFilter, filter.TimeseriesFilter, maxLimit, "initialized_at", func(ex CronExecLog) (time.Time, string) {
+		return ex.InitializedAt, ex.ID
+	})
+}
+
+// execFilterQuery returns the query of the executions which match the
+// filter. The time range can be open-ended, the same as for the logs.
+func execFilterQuery(filter CronExecFilter) (bson.M, error) {
 	queryFilter := bson.M{}
 
 	from, to := filter.From, filter.To
 
-	// if the from and to fields are not zero, add them to the query filter
-	if !from.IsZero() && !to.IsZero() {
-		if from.After(to) {
-			return nil, errors.New("from date cannot be after to date")
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return nil, errors.New("from date cannot be after to date")
+	}
+
+	if !from.IsZero() || !to.IsZero() {
+		initializedAt := bson.M{}
+		if !from.IsZero() {
+			initializedAt["$gte"] = from
 		}
 
-		queryFilter["initialized_at"] = bson.M{"$gte": from, "$lte": to}
+		if !to.IsZero() {
+			initializedAt["$lte"] = to
+		}
+
+		queryFilter["initialized_at"] = initializedAt
 	}
 
 	if filter.Source != "" {
@@ -289,21 +566,223 @@ func (m *MongoCronStorage) FindExecutions(filter CronExecFilter, maxLimit int64)
 		queryFilter["execution_time"] = bson.M{"$gte": filter.ExecutionTime}
 	}
 
+	return queryFilter, nil
+}
+
+// --------------- Task Queue Logic ---------------
+
+// MongoTaskStorage implementation of the TaskStorage interface
+type MongoTaskStorage struct {
+	coll *mongo.Collection
+}
+
+var _ TaskStorage = (*MongoTaskStorage)(nil)
+
+func NewMongoTaskStorage(coll *mongo.Collection) (*MongoTaskStorage, error) {
+	if coll == nil {
+		return nil, fmt.Errorf("collection cannot be nil")
+	}
+
+	return &MongoTaskStorage{coll: coll}, nil
+}
+
+func (m *MongoTaskStorage) CreateIndexes() error {
+	return newMongoIndexes().
+		Add("source", "status", "run_at").
+		Add("lease_until").
+		Add("handler").
+		Create(m.coll)
+}
+
+func (m *MongoTaskStorage) EnqueueTask(t *Task) error {
+	if t == nil {
+		return fmt.Errorf("task cannot be nil")
+	}
+
+	_, err := m.coll.InsertOne(context.Background(), t)
+	return err
+}
+
+// ClaimTask atomically sets the oldest due task to running, so that
+// multiple workers do not claim the same task.
+func (m *MongoTaskStorage) ClaimTask(source, workerID string, now time.Time, lease time.Duration) (*Task, error) {
+	filter := bson.M{
+		"source": source,
+		"$or": bson.A{
+			bson.M{"status": TaskStatusPending, "run_at": bson.M{"$lte": now}},
+			bson.M{"status": TaskStatusRunning, "lease_until": bson.M{"$lt": now}},
+		},
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":      TaskStatusRunning,
+			"worker_id":   workerID,
+			"lease_until": now.Add(lease),
+			"updated_at":  now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "run_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var t Task
+	if err := m.coll.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&t); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (m *MongoTaskStorage) UpdateTask(t *Task) error {
+	if t == nil {
+		return fmt.Errorf("task cannot be nil")
+	}
+
+	filter := bson.M{
+		"_id":       t.ID,
+		"worker_id": t.WorkerID,
+		"status":    TaskStatusRunning,
+	}
+
+	res, err := m.coll.UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{
+		"status":     t.Status,
+		"run_at":     t.RunAt,
+		"error":      t.Error,
+		"updated_at": t.UpdatedAt,
+	}})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("task %v is no longer owned by the worker", t.ID)
+	}
+
+	return nil
+}
+
+// FindTasks returns a list of tasks based on the filter
+func (m *MongoTaskStorage) FindTasks(filter TaskFilter, maxLimit int64) ([]Task, error) {
+	queryFilter := bson.M{}
+
+	from, to := filter.From, filter.To
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return nil, errors.New("from date cannot be after to date")
+	}
+
+	// the from and to can be used separately, same as for the memory storage
+	if !from.IsZero() || !to.IsZero() {
+		runAt := bson.M{}
+		if !from.IsZero() {
+			runAt["$gte"] = from
+		}
+
+		if !to.IsZero() {
+			runAt["$lte"] = to
+		}
+
+		queryFilter["run_at"] = runAt
+	}
+
+	if filter.Source != "" {
+		queryFilter["source"] = filter.Source
+	}
+
+	if filter.Handler != "" {
+		queryFilter["handler"] = filter.Handler
+	}
+
+	if filter.Status != "" {
+		queryFilter["status"] = filter.Status
+	}
+
 	userLimit := filter.TimeseriesFilter.Limit
 	if userLimit > maxLimit {
 		userLimit = maxLimit
 	}
 
 	opts := options.Find().
-		SetSort(bson.D{{Key: "initialized_at", Value: -1}}).
-		SetLimit(int64(userLimit)).
+		SetSort(bson.D{{Key: "run_at", Value: -1}}).
+		SetLimit(userLimit).
 		SetSkip(filter.TimeseriesFilter.Skip)
 
-	var docs []CronExecLog
-	err := mongoGetDocuments(m.cronHistoryColl, queryFilter, opts, &docs)
+	var docs []Task
+	err := mongoGetDocuments(m.coll, queryFilter, opts, &docs)
 	return docs, err
 }
 
+// mongoFindPage returns a page of the documents which match the filter,
+// sorted by the time field and the _id in descending order. If the
+// cursor is specified, the documents after it are returned, otherwise
+// the skip of the filter is used.
+func mongoFindPage[T any](coll *mongo.Collection, query bson.M, filter TimeseriesFilter, maxLimit int64, timeField string, cursorOf func(T) (time.Time, string)) (*Page[T], error) {
+	page := &Page[T]{}
+
+	if filter.Total {
+		total, err := coll.CountDocuments(context.Background(), query)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Total = &total
+	}
+
+	userLimit := filter.Limit
+	if userLimit > maxLimit {
+		userLimit = maxLimit
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: timeField, Value: -1}, {Key: "_id", Value: -1}})
+
+	// one more document is requested to know if there is a next page
+	if userLimit > 0 {
+		opts.SetLimit(userLimit + 1)
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		var id any = cursor.ID
+		if oid, err := primitive.ObjectIDFromHex(cursor.ID); err == nil {
+			id = oid
+		}
+
+		query = bson.M{"$and": []bson.M{query, {"$or": []bson.M{
+			{timeField: bson.M{"$lt": cursor.Time}},
+			{timeField: cursor.Time, "_id": bson.M{"$lt": id}},
+		}}}}
+	} else {
+		opts.SetSkip(filter.Skip)
+	}
+
+	var docs []T
+	if err := mongoGetDocuments(coll, query, opts, &docs); err != nil {
+		return nil, err
+	}
+
+	if userLimit > 0 && int64(len(docs)) > userLimit {
+		docs = docs[:userLimit]
+		t, id := cursorOf(docs[len(docs)-1])
+		page.NextCursor = encodeCursor(t, id)
+	}
+
+	if docs == nil {
+		docs = []T{}
+	}
+
+	page.Items = docs
+	return page, nil
+}
+
 // unexposed mongo specific utility function
 func mongoGetDocuments[T any](coll *mongo.Collection, filter primitive.M, options *options.FindOptions, results *[]T) error {
 	ctx := context.Background()
@@ -0,0 +1,198 @@
+package syro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LogRetention decides for how long the logs of the MongoLogger are kept.
+//
+// The TTL is enforced by mongo with a TTL index on the timestamp, which is
+// created by CreateIndexes. The retention of the levels is enforced by
+// Prune, so it has to be called periodically (e.g. with the PruneJob).
+// A level retention longer than the TTL has no effect, because mongo
+// removes the logs after the TTL.
+type LogRetention struct {
+	TTL    time.Duration              // Optional. Logs older than this are removed by mongo
+	Levels map[LogLevel]time.Duration // Optional. Retention of the logs with the level (e.g. 7 days for debug)
+}
+
+const ttlIndexName = "timestamp_ttl"
+
+// WithRetention returns a copy of the logger with the retention of the logs.
+// CreateIndexes has to be called to create or update the TTL index. The
+// receiver and its child loggers are not modified.
+func (lg *MongoLogger) WithRetention(r LogRetention) *MongoLogger {
+	c := lg.clone()
+	c.Retention = &r
+	return c
+}
+
+// createTTLIndex creates the TTL index on the timestamp or updates the
+// expiration of the existing index.
+func (lg *MongoLogger) createTTLIndex(ttl time.Duration) error {
+	seconds := int32(ttl.Seconds())
+	if seconds <= 0 {
+		return errors.New("ttl has to be at least 1s")
+	}
+
+	ctx := context.Background()
+	model := mongo.IndexModel{
+		Keys:    bson.D{{Key: "timestamp", Value: 1}},
+		Options: options.Index().SetName(ttlIndexName).SetExpireAfterSeconds(seconds),
+	}
+
+	_, err := lg.Coll.Indexes().CreateOne(ctx, model)
+	if err == nil {
+		return nil
+	}
+
+	// the index already exists with a different ttl
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || (cmdErr.Code != 85 && cmdErr.Code != 86) {
+		return fmt.Errorf("failed to create ttl index for %v collection: %v", lg.Coll.Name(), err)
+	}
+
+	cmd := bson.D{
+		{Key: "collMod", Value: lg.Coll.Name()},
+		{Key: "index", Value: bson.D{{Key: "name", Value: ttlIndexName}, {Key: "expireAfterSeconds", Value: seconds}}},
+	}
+
+	if err := lg.Coll.Database().RunCommand(ctx, cmd).Err(); err != nil {
+		return fmt.Errorf("failed to update ttl index for %v collection: %v", lg.Coll.Name(), err)
+	}
+
+	return nil
+}
+
+// Prune removes the logs which are older than the retention of their level.
+// Returns the number of removed logs.
+func (lg *MongoLogger) Prune(ctx context.Context) (int64, error) {
+	if lg.Retention == nil {
+		return 0, nil
+	}
+
+	var deleted int64
+	for _, filter := range levelPruneFilters(lg.Retention.Levels, time.Now().UTC()) {
+		res, err := lg.Coll.DeleteMany(ctx, filter)
+		if err != nil {
+			return deleted, err
+		}
+
+		deleted += res.DeletedCount
+	}
+
+	return deleted, nil
+}
+
+// levelPruneFilters returns the filters of the logs which are older than the
+// retention of their level, sorted by the level.
+func levelPruneFilters(levels map[LogLevel]time.Duration, now time.Time) []bson.M {
+	filters := make([]bson.M, 0, len(levels))
+	for level, retention := range levels {
+		if retention > 0 {
+			filters = append(filters, bson.M{"level": level, "timestamp": bson.M{"$lt": now.Add(-retention)}})
+		}
+	}
+
+	sort.Slice(filters, func(i, j int) bool { return filters[i]["level"].(LogLevel) < filters[j]["level"].(LogLevel) })
+	return filters
+}
+
+// PruneJob returns a job which calls Prune with the schedule, so that it
+// can be registered with CronScheduler.Register.
+func (lg *MongoLogger) PruneJob(schedule string) *Job {
+	return &Job{
+		Name:        "prune-logs-" + lg.Coll.Name(),
+		Schedule:    schedule,
+		Description: "Remove the logs which are older than the retention of their level",
+		Logger:      lg,
+		FuncCtx: func(ctx context.Context) error {
+			_, err := lg.Prune(ctx)
+			return err
+		},
+	}
+}
+
+// LogCollectionOptions are the options of the collection created with
+// CreateLogCollection. TimeSeries and Capped cannot be used together.
+type LogCollectionOptions struct {
+	TimeSeries  bool          // Optional. Create a time-series collection with the timestamp as the time field
+	Granularity string        // Optional. Granularity of the time-series collection ("seconds", "minutes" or "hours")
+	ExpireAfter time.Duration // Optional. Expiration of the documents in the time-series collection
+	Capped      bool          // Optional. Create a capped collection, or convert the existing one
+	SizeBytes   int64         // Max size of the capped collection in bytes, required if Capped is true
+	MaxDocs     int64         // Optional. Max number of documents in the capped collection
+}
+
+// CreateLogCollection creates the collection for the MongoLogger as a
+// time-series or a capped collection. If the collection already exists,
+// it is converted to a capped collection if needed. Existing collections
+// cannot be converted to time-series collections.
+//
+// Note that the TTL index cannot be created for capped collections.
+func CreateLogCollection(ctx context.Context, db *mongo.Database, name string, opts LogCollectionOptions) (*mongo.Collection, error) {
+	if opts.TimeSeries && opts.Capped {
+		return nil, errors.New("collection cannot be both time-series and capped")
+	}
+
+	if opts.Capped && opts.SizeBytes <= 0 {
+		return nil, errors.New("size of the capped collection has to be specified")
+	}
+
+	specs, err := db.ListCollectionSpecifications(ctx, bson.M{"name": name})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(specs) == 0 {
+		createOpts := options.CreateCollection()
+
+		if opts.TimeSeries {
+			ts := options.TimeSeries().SetTimeField("timestamp")
+			if opts.Granularity != "" {
+				ts.SetGranularity(opts.Granularity)
+			}
+
+			createOpts.SetTimeSeriesOptions(ts)
+			if opts.ExpireAfter > 0 {
+				createOpts.SetExpireAfterSeconds(int64(opts.ExpireAfter.Seconds()))
+			}
+		}
+
+		if opts.Capped {
+			createOpts.SetCapped(true).SetSizeInBytes(opts.SizeBytes)
+			if opts.MaxDocs > 0 {
+				createOpts.SetMaxDocuments(opts.MaxDocs)
+			}
+		}
+
+		if err := db.CreateCollection(ctx, name, createOpts); err != nil {
+			return nil, err
+		}
+
+		return db.Collection(name), nil
+	}
+
+	spec := specs[0]
+
+	if opts.TimeSeries && spec.Type != "timeseries" {
+		return nil, fmt.Errorf("existing %v collection cannot be converted to a time-series collection", name)
+	}
+
+	if capped, _ := spec.Options.Lookup("capped").BooleanOK(); opts.Capped && !capped {
+		cmd := bson.D{{Key: "convertToCapped", Value: name}, {Key: "size", Value: opts.SizeBytes}}
+		if err := db.RunCommand(ctx, cmd).Err(); err != nil {
+			return nil, fmt.Errorf("failed to convert %v collection to capped: %v", name, err)
+		}
+	}
+
+	return db.Collection(name), nil
+}
@@ -0,0 +1,219 @@
+package syro
+
+import (
+	"errors"
+)
+
+// MultiSink is one of the loggers of the MultiLogger.
+type MultiSink struct {
+	Logger   Logger   // Logger to which the logs are written
+	MinLevel LogLevel // Optional. Logs below the level are not written to the sink
+}
+
+// MultiLogger is a Logger which writes every log to all of the sinks. The
+// FindLogs and LogExists methods use the queryable sink, which can be
+// set with WithQueryable.
+//
+// To avoid duplicate console output when combining the ConsoleLogger with
+// the MongoLogger, set LoggerSettings.DisableConsole for the MongoLogger.
+type MultiLogger struct {
+	Settings  *LoggerSettings
+	Sinks     []MultiSink
+	Queryable Logger // Optional. Logger which is used for the FindLogs and LogExists methods
+	Source    string
+	Event     string
+	EventID   string
+	Fields    LogFields
+}
+
+var _ Logger = (*MultiLogger)(nil)
+
+func NewMultiLogger(settings *LoggerSettings, sinks ...MultiSink) *MultiLogger {
+	return &MultiLogger{Settings: settings, Sinks: sinks}
+}
+
+// WithQueryable sets the logger which is used for the FindLogs and LogExists
+// methods. Usually it is one of the sinks (e.g. the MongoLogger).
+func (lg *MultiLogger) WithQueryable(logger Logger) *MultiLogger {
+	c := lg.clone()
+	c.Queryable = logger
+	return c
+}
+
+func (lg *MultiLogger) GetProps() LoggerProps {
+	return LoggerProps{
+		Settings: lg.Settings,
+		Source:   lg.Source,
+		Event:    lg.Event,
+		EventID:  lg.EventID,
+		Fields:   lg.Fields,
+	}
+}
+
+func (lg *MultiLogger) Name() string { return "multi" }
+
+// GetTableName returns the table name of the queryable sink.
+func (lg *MultiLogger) GetTableName() string {
+	if lg.Queryable == nil {
+		return ""
+	}
+
+	return lg.Queryable.GetTableName()
+}
+
+// log writes the log to all of the sinks which accept the level. The
+// errors of the sinks are returned as an ErrGroup.
+func (lg *MultiLogger) log(level LogLevel, msg string, lf ...LogFields) error {
+	_, _, err := lg.record(level, msg, lf...)
+	return err
+}
+
+// record writes the log to all of the sinks and returns the log of the first
+// sink which stores the logs (implements LogWriter), or of the first sink
+// if none of them store the logs.
+func (lg *MultiLogger) record(level LogLevel, msg string, lf ...LogFields) (Log, bool, error) {
+	if !lg.Settings.Enabled(level, lg.Source) {
+		return Log{}, false, nil
+	}
+
+	// the redaction is applied before the sinks receive the log
+	if lg.Settings != nil && lg.Settings.Redaction != nil {
+		log := lg.Settings.Redact(NewLog(level, msg, lg.Source, lg.Event, lg.EventID, mergeFields(nil, lf...)))
+		msg, lf = log.Message, nil
+		if log.Fields != nil {
+			lf = []LogFields{log.Fields}
+		}
+	}
+
+	eg := NewErrGroup(ErrGroupProps{ID: "multi logger"})
+
+	var recorded Log
+	var ok, stored bool
+	for _, sink := range lg.Sinks {
+		if sink.Logger == nil || level < sink.MinLevel {
+			continue
+		}
+
+		if stored {
+			eg.Add(logWithLevel(sink.Logger, level, msg, lf...))
+			continue
+		}
+
+		log, written, err := recordLog(sink.Logger, level, msg, lf...)
+		eg.Add(err)
+
+		if _, isWriter := sink.Logger.(LogWriter); written && (!ok || isWriter) {
+			recorded, ok, stored = log, true, isWriter
+		}
+	}
+
+	return recorded, ok, eg.ToErr()
+}
+
+// WriteLogs writes the logs to all of the sinks, using a batch write for the
+// sinks which implement the LogWriter interface.
+func (lg *MultiLogger) WriteLogs(logs []Log) error {
+	logs = enabledLogs(lg.Settings, logs)
+	eg := NewErrGroup(ErrGroupProps{ID: "multi logger"})
+
+	for _, sink := range lg.Sinks {
+		if sink.Logger == nil {
+			continue
+		}
+
+		var sinkLogs []Log
+		for _, log := range logs {
+			if log.Level >= sink.MinLevel {
+				sinkLogs = append(sinkLogs, log)
+			}
+		}
+
+		if len(sinkLogs) > 0 {
+			eg.Add(writeLogs(sink.Logger, sinkLogs))
+		}
+	}
+
+	return eg.ToErr()
+}
+
+// clone returns a copy of the logger with a new list of sinks.
+func (lg *MultiLogger) clone() *MultiLogger {
+	c := *lg
+	c.Sinks = append([]MultiSink{}, lg.Sinks...)
+	return &c
+}
+
+// withSinks returns a copy of the logger where fn is applied to each of the sinks.
+func (lg *MultiLogger) withSinks(fn func(Logger) Logger) *MultiLogger {
+	c := lg.clone()
+	for i := range c.Sinks {
+		if c.Sinks[i].Logger != nil {
+			c.Sinks[i].Logger = fn(c.Sinks[i].Logger)
+		}
+	}
+
+	return c
+}
+
+func (lg *MultiLogger) WithSource(v string) Logger {
+	c := lg.withSinks(func(l Logger) Logger { return l.WithSource(v) })
+	c.Source = v
+	return c
+}
+
+func (lg *MultiLogger) WithEvent(v string) Logger {
+	c := lg.withSinks(func(l Logger) Logger { return l.WithEvent(v) })
+	c.Event = v
+	return c
+}
+
+func (lg *MultiLogger) WithEventID(v string) Logger {
+	c := lg.withSinks(func(l Logger) Logger { return l.WithEventID(v) })
+	c.EventID = v
+	return c
+}
+
+func (lg *MultiLogger) With(fields LogFields) Logger {
+	// the default fields are passed to the sinks, so they are redacted first
+	if lg.Settings != nil && lg.Settings.Redaction != nil && fields != nil {
+		fields = lg.Settings.Redaction.redactFields(fields)
+	}
+
+	c := lg.withSinks(func(l Logger) Logger { return l.With(fields) })
+	c.Fields = mergeFields(lg.Fields, fields)
+	return c
+}
+
+func (lg *MultiLogger) Debug(msg string, lf ...LogFields) error { return lg.log(DEBUG, msg, lf...) }
+func (lg *MultiLogger) Trace(msg string, lf ...LogFields) error { return lg.log(TRACE, msg, lf...) }
+func (lg *MultiLogger) Error(msg string, lf ...LogFields) error { return lg.log(ERROR, msg, lf...) }
+func (lg *MultiLogger) Info(msg string, lf ...LogFields) error  { return lg.log(INFO, msg, lf...) }
+func (lg *MultiLogger) Warn(msg string, lf ...LogFields) error  { return lg.log(WARN, msg, lf...) }
+func (lg *MultiLogger) Fatal(msg string, lf ...LogFields) error { return lg.log(FATAL, msg, lf...) }
+
+var errNoQueryableSink = errors.New("multi logger does not have a queryable sink")
+
+func (lg *MultiLogger) LogExists(filter any) (bool, error) {
+	if lg.Queryable == nil {
+		return false, errNoQueryableSink
+	}
+
+	return lg.Queryable.LogExists(filter)
+}
+
+func (lg *MultiLogger) FindLogs(filter LogFilter, maxLimit int64) ([]Log, error) {
+	if lg.Queryable == nil {
+		return nil, errNoQueryableSink
+	}
+
+	return lg.Queryable.FindLogs(filter, maxLimit)
+}
+
+// FindLogsPage returns a page of the logs from the queryable sink.
+func (lg *MultiLogger) FindLogsPage(filter LogFilter, maxLimit int64) (*Page[Log], error) {
+	if lg.Queryable == nil {
+		return nil, errNoQueryableSink
+	}
+
+	return findLogsPage(lg.Queryable, filter, maxLimit)
+}
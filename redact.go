@@ -0,0 +1,208 @@
+package syro
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Redactor is a custom func which removes the sensitive data from the log.
+// The fields of the log are a copy, so they can be modified.
+type Redactor func(log *Log)
+
+// RedactRule is a pattern whose matches are replaced only if they pass the
+// validation, e.g. the Luhn checksum of the card numbers.
+type RedactRule struct {
+	Pattern  *regexp.Regexp
+	Validate func(match string) bool // Optional. Reports if the match is redacted, all of the matches are redacted if nil
+}
+
+// Redaction removes the sensitive data from the logs before they are
+// written by any of the sinks of the logger (and printed to the console).
+// The keys are applied first, then the patterns, the rules and then the
+// redactors.
+type Redaction struct {
+	Keys      []string         // Optional. Keys of the fields (including nested ones) whose values are replaced, case insensitive
+	Patterns  []*regexp.Regexp // Optional. Matches in the message and the string values of the fields are replaced
+	Rules     []RedactRule     // Optional. Same as the patterns, but only the valid matches are replaced
+	Redactors []Redactor       // Optional. Custom funcs which are called for every log
+	Mask      string           // Optional. Replacement of the redacted values, defaults to "[REDACTED]"
+}
+
+const defaultRedactionMask = "[REDACTED]"
+
+var (
+	// DefaultRedactKeys are the common keys of the fields with credentials.
+	DefaultRedactKeys = []string{"password", "passwd", "secret", "token", "access_token", "refresh_token", "api_key", "apikey", "authorization", "cookie", "set-cookie"}
+
+	RedactEmails       = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	RedactBearerTokens = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+
+	// RedactCardNumbers matches 13 to 19 digits, optionally separated by
+	// spaces or dashes. Only the matches which pass the Luhn checksum are
+	// redacted, so that e.g. the timestamps and the ids are kept.
+	RedactCardNumbers = RedactRule{Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Validate: luhnValid}
+)
+
+// DefaultRedaction returns the redaction of the DefaultRedactKeys, emails,
+// bearer tokens and card numbers.
+func DefaultRedaction() *Redaction {
+	return &Redaction{
+		Keys:     append([]string{}, DefaultRedactKeys...),
+		Patterns: []*regexp.Regexp{RedactEmails, RedactBearerTokens},
+		Rules:    []RedactRule{RedactCardNumbers},
+	}
+}
+
+// Redact returns the log with the redaction of the settings applied. The
+// fields of the log are copied, so the maps which were passed to the
+// logger are not modified. Returns the log as it is if the settings or
+// the redaction are nil.
+func (s *LoggerSettings) Redact(log Log) Log {
+	if s == nil || s.Redaction == nil {
+		return log
+	}
+
+	return s.Redaction.redact(log)
+}
+
+func (r *Redaction) redact(log Log) Log {
+	log.Message = r.redactString(log.Message)
+	if log.Fields != nil {
+		log.Fields = r.redactFields(log.Fields)
+	}
+
+	for _, fn := range r.Redactors {
+		fn(&log)
+	}
+
+	return log
+}
+
+func (r *Redaction) mask() string {
+	if r.Mask == "" {
+		return defaultRedactionMask
+	}
+
+	return r.Mask
+}
+
+func (r *Redaction) redactString(v string) string {
+	for _, re := range r.Patterns {
+		v = re.ReplaceAllString(v, r.mask())
+	}
+
+	for _, rule := range r.Rules {
+		if rule.Pattern == nil {
+			continue
+		}
+
+		if rule.Validate == nil {
+			v = rule.Pattern.ReplaceAllString(v, r.mask())
+			continue
+		}
+
+		v = rule.Pattern.ReplaceAllStringFunc(v, func(match string) string {
+			if rule.Validate(match) {
+				return r.mask()
+			}
+			return match
+		})
+	}
+
+	return v
+}
+
+// luhnValid reports if the digits of the value pass the Luhn checksum of
+// the card numbers. The other characters are ignored.
+func luhnValid(v string) bool {
+	sum, double := 0, false
+	for i := len(v) - 1; i >= 0; i-- {
+		if v[i] < '0' || v[i] > '9' {
+			continue
+		}
+
+		d := int(v[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+func (r *Redaction) redactKey(key string) bool {
+	for _, k := range r.Keys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactFields returns a copy of the fields with the redacted values.
+func (r *Redaction) redactFields(fields map[string]any) LogFields {
+	redacted := make(LogFields, len(fields))
+	for k, v := range fields {
+		if r.redactKey(k) {
+			redacted[k] = r.mask()
+		} else {
+			redacted[k] = r.redactValue(v)
+		}
+	}
+
+	return redacted
+}
+
+func (r *Redaction) redactValue(v any) any {
+	switch val := v.(type) {
+	case string:
+		return r.redactString(val)
+	case LogFields:
+		return r.redactFields(val)
+	case map[string]any:
+		return map[string]any(r.redactFields(val))
+	case map[string]string:
+		redacted := make(map[string]string, len(val))
+		for k, s := range val {
+			if r.redactKey(k) {
+				redacted[k] = r.mask()
+			} else {
+				redacted[k] = r.redactString(s)
+			}
+		}
+		return redacted
+	case map[string][]string:
+		redacted := make(map[string][]string, len(val))
+		for k, values := range val {
+			if r.redactKey(k) {
+				redacted[k] = []string{r.mask()}
+			} else {
+				redacted[k] = r.redactValue(values).([]string)
+			}
+		}
+		return redacted
+	case http.Header:
+		return http.Header(r.redactValue(map[string][]string(val)).(map[string][]string))
+	case []any:
+		redacted := make([]any, len(val))
+		for i, item := range val {
+			redacted[i] = r.redactValue(item)
+		}
+		return redacted
+	case []string:
+		redacted := make([]string, len(val))
+		for i, s := range val {
+			redacted[i] = r.redactString(s)
+		}
+		return redacted
+	default:
+		return v
+	}
+}
@@ -0,0 +1,329 @@
+package syro
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// ----------- slog.Handler which writes to a Logger -----------
+
+// SlogHandler is a slog.Handler which writes the records to the Logger, so
+// that the code which uses the log/slog API can be used with any of the
+// Logger implementations (e.g. the MongoLogger).
+//
+// The attrs are converted to LogFields, groups are stored as nested
+// LogFields. The top level attrs with the Source, Event and EventID
+// keys are used as the properties of the log instead.
+type SlogHandler struct {
+	logger Logger
+	opts   SlogHandlerOptions
+	fields LogFields
+	groups []string
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// SlogHandlerOptions are the keys of the attrs which are used as the
+// properties of the log.
+type SlogHandlerOptions struct {
+	SourceKey  string // defaults to "source"
+	EventKey   string // defaults to "event"
+	EventIDKey string // defaults to "event_id"
+}
+
+func NewSlogHandler(logger Logger, opts *SlogHandlerOptions) *SlogHandler {
+	h := &SlogHandler{
+		logger: logger,
+		opts:   SlogHandlerOptions{SourceKey: "source", EventKey: "event", EventIDKey: "event_id"},
+	}
+
+	if opts != nil {
+		if opts.SourceKey != "" {
+			h.opts.SourceKey = opts.SourceKey
+		}
+
+		if opts.EventKey != "" {
+			h.opts.EventKey = opts.EventKey
+		}
+
+		if opts.EventIDKey != "" {
+			h.opts.EventIDKey = opts.EventIDKey
+		}
+	}
+
+	return h
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	props := h.logger.GetProps()
+	return props.Settings.Enabled(logLevelFromSlog(level), props.Source)
+}
+
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	logger := h.logger
+	fields := copyFields(h.fields)
+
+	r.Attrs(func(a slog.Attr) bool {
+		logger = h.addAttr(logger, fields, a)
+		return true
+	})
+
+	var lf []LogFields
+	if len(fields) > 0 {
+		lf = append(lf, fields)
+	}
+
+	return logWithLevel(logger, logLevelFromSlog(r.Level), r.Message, lf...)
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.fields = copyFields(h.fields)
+
+	for _, a := range attrs {
+		c.logger = c.addAttr(c.logger, c.fields, a)
+	}
+
+	return &c
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	c := *h
+	c.groups = append(append([]string{}, h.groups...), name)
+	return &c
+}
+
+// addAttr adds the attr to the fields, using the current groups of the
+// handler. If the attr is one of the property keys, the returned
+// logger has the property set instead.
+func (h *SlogHandler) addAttr(logger Logger, fields LogFields, a slog.Attr) Logger {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return logger
+	}
+
+	if len(h.groups) == 0 && a.Value.Kind() == slog.KindString {
+		switch a.Key {
+		case h.opts.SourceKey:
+			return logger.WithSource(a.Value.String())
+		case h.opts.EventKey:
+			return logger.WithEvent(a.Value.String())
+		case h.opts.EventIDKey:
+			return logger.WithEventID(a.Value.String())
+		}
+	}
+
+	// find or create the nested fields for the groups of the handler
+	target := fields
+	for _, group := range h.groups {
+		nested, ok := target[group].(LogFields)
+		if !ok {
+			nested = LogFields{}
+		} else {
+			nested = copyFields(nested)
+		}
+
+		target[group] = nested
+		target = nested
+	}
+
+	// inline the attrs of groups without a key
+	if a.Key == "" && a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			target[ga.Key] = slogValue(ga.Value)
+		}
+		return logger
+	}
+
+	target[a.Key] = slogValue(a.Value)
+	return logger
+}
+
+// slogValue converts the slog value to a value which can be stored in the LogFields.
+func slogValue(v slog.Value) any {
+	v = v.Resolve()
+
+	switch v.Kind() {
+	case slog.KindGroup:
+		fields := LogFields{}
+		for _, a := range v.Group() {
+			fields[a.Key] = slogValue(a.Value)
+		}
+		return fields
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+		return v.Any()
+	default:
+		return v.Any()
+	}
+}
+
+// copyFields returns a shallow copy of the fields, nested LogFields are
+// copied when they are modified.
+func copyFields(fields LogFields) LogFields {
+	c := make(LogFields, len(fields))
+	for k, v := range fields {
+		c[k] = v
+	}
+
+	return c
+}
+
+func logLevelFromSlog(l slog.Level) LogLevel {
+	switch {
+	case l < slog.LevelDebug:
+		return TRACE
+	case l < slog.LevelInfo:
+		return DEBUG
+	case l < slog.LevelWarn:
+		return INFO
+	case l < slog.LevelError:
+		return WARN
+	case l < slog.LevelError+4:
+		return ERROR
+	default:
+		return FATAL
+	}
+}
+
+func slogLevel(l LogLevel) slog.Level {
+	switch l {
+	case TRACE:
+		return slog.LevelDebug - 4
+	case DEBUG:
+		return slog.LevelDebug
+	case WARN:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	case FATAL:
+		return slog.LevelError + 4
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// ----------- Logger implementation for slog -----------
+
+// SlogLogger is a Logger which writes the logs to the *slog.Logger. The
+// source, event, event id and the fields of the log are written as
+// attrs. Logs cannot be queried with this implementation.
+type SlogLogger struct {
+	Logger   *slog.Logger
+	Settings *LoggerSettings
+	Source   string
+	Event    string
+	EventID  string
+	Fields   LogFields // Default fields which are added to every log
+}
+
+var _ Logger = (*SlogLogger)(nil)
+
+func NewSlogLogger(logger *slog.Logger, settings *LoggerSettings) *SlogLogger {
+	return &SlogLogger{Logger: logger, Settings: settings}
+}
+
+func (lg *SlogLogger) GetProps() LoggerProps {
+	return LoggerProps{
+		Settings: lg.Settings,
+		Source:   lg.Source,
+		Event:    lg.Event,
+		EventID:  lg.EventID,
+		Fields:   lg.Fields,
+	}
+}
+
+func (lg *SlogLogger) Name() string { return "slog" }
+
+func (lg *SlogLogger) GetTableName() string { return "" }
+
+func (lg *SlogLogger) log(level LogLevel, msg string, lf ...LogFields) error {
+	_, _, err := lg.record(level, msg, lf...)
+	return err
+}
+
+func (lg *SlogLogger) record(level LogLevel, msg string, lf ...LogFields) (Log, bool, error) {
+	if !lg.Settings.Enabled(level, lg.Source) {
+		return Log{}, false, nil
+	}
+
+	log := newLog(lg.Settings, level, msg, lg.Source, lg.Event, lg.EventID, mergeFields(lg.Fields, lf...))
+
+	attrs := make([]slog.Attr, 0, len(log.Fields)+3)
+	if log.Source != "" {
+		attrs = append(attrs, slog.String("source", log.Source))
+	}
+
+	if log.Event != "" {
+		attrs = append(attrs, slog.String("event", log.Event))
+	}
+
+	if log.EventID != "" {
+		attrs = append(attrs, slog.String("event_id", log.EventID))
+	}
+
+	keys := make([]string, 0, len(log.Fields))
+	for k := range log.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, log.Fields[k]))
+	}
+
+	lg.Logger.LogAttrs(context.Background(), slogLevel(level), log.Message, attrs...)
+	return log, true, nil
+}
+
+func (lg *SlogLogger) clone() *SlogLogger {
+	c := *lg
+	return &c
+}
+
+func (lg *SlogLogger) WithSource(v string) Logger {
+	c := lg.clone()
+	c.Source = v
+	return c
+}
+
+func (lg *SlogLogger) WithEvent(v string) Logger {
+	c := lg.clone()
+	c.Event = v
+	return c
+}
+
+func (lg *SlogLogger) WithEventID(v string) Logger {
+	c := lg.clone()
+	c.EventID = v
+	return c
+}
+
+func (lg *SlogLogger) With(fields LogFields) Logger {
+	c := lg.clone()
+	c.Fields = mergeFields(lg.Fields, fields)
+	return c
+}
+
+func (lg *SlogLogger) Debug(msg string, lf ...LogFields) error { return lg.log(DEBUG, msg, lf...) }
+func (lg *SlogLogger) Trace(msg string, lf ...LogFields) error { return lg.log(TRACE, msg, lf...) }
+func (lg *SlogLogger) Error(msg string, lf ...LogFields) error { return lg.log(ERROR, msg, lf...) }
+func (lg *SlogLogger) Info(msg string, lf ...LogFields) error  { return lg.log(INFO, msg, lf...) }
+func (lg *SlogLogger) Warn(msg string, lf ...LogFields) error  { return lg.log(WARN, msg, lf...) }
+func (lg *SlogLogger) Fatal(msg string, lf ...LogFields) error { return lg.log(FATAL, msg, lf...) }
+
+func (lg *SlogLogger) LogExists(filter any) (bool, error) {
+	return false, fmt.Errorf("method cannot be used with SlogLogger")
+}
+
+func (lg *SlogLogger) FindLogs(filter LogFilter, maxLimit int64) ([]Log, error) {
+	return nil, fmt.Errorf("method cannot be used with SlogLogger")
+}
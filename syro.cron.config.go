@@ -0,0 +1,335 @@
+package syro
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// JobRegistry stores the functions which can be referenced by key from the
+// jobs that are defined in a config file.
+type JobRegistry struct {
+	mu    sync.RWMutex
+	funcs map[string]func(ctx context.Context) error
+}
+
+func NewJobRegistry() *JobRegistry {
+	return &JobRegistry{funcs: make(map[string]func(ctx context.Context) error)}
+}
+
+// Add binds the function to the key. If the key already exists, the
+// previous function is replaced.
+func (r *JobRegistry) Add(key string, fn func(ctx context.Context) error) *JobRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.funcs[key] = fn
+	return r
+}
+
+// Get returns the function which is bound to the key.
+func (r *JobRegistry) Get(key string) (func(ctx context.Context) error, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, ok := r.funcs[key]
+	return fn, ok && fn != nil
+}
+
+// CronConfig is the structure of the file which can be loaded with
+// CronScheduler.LoadConfig.
+type CronConfig struct {
+	Jobs []JobConfig `json:"jobs" yaml:"jobs"`
+}
+
+// JobConfig is the declarative definition of a Job.
+type JobConfig struct {
+	Name         string   `json:"name" yaml:"name"`                           // Name of the job
+	Func         string   `json:"func" yaml:"func"`                           // Optional. Key of the function in the JobRegistry, defaults to the name of the job
+	Schedule     string   `json:"schedule" yaml:"schedule"`                   // Schedule of the job (e.g. "0 0 * * *" or "@every 1h")
+	Description  string   `json:"description" yaml:"description"`             // Optional. Description of the job
+	Timeout      string   `json:"timeout" yaml:"timeout"`                     // Optional. Duration string (e.g. "30s"), see Job.Timeout
+	Retries      int      `json:"retries" yaml:"retries"`                     // Optional. See Job.Retries
+	RetryDelay   string   `json:"retry_delay" yaml:"retry_delay"`             // Optional. Duration string (e.g. "5s"), see Job.RetryDelay
+	AllowOverlap bool     `json:"allow_overlap" yaml:"allow_overlap"`         // Optional. See Job.AllowOverlap
+	Tags         []string `json:"tags" yaml:"tags"`                           // Optional. See Job.Tags
+	Group        string   `json:"concurrency_group" yaml:"concurrency_group"` // Optional. See Job.ConcurrencyGroup
+	DependsOn    []string `json:"depends_on" yaml:"depends_on"`               // Optional. See Job.DependsOn, the schedule can be empty if set
+}
+
+// hasJob reports if the config has a job with the name.
+func (cfg *CronConfig) hasJob(name string) bool {
+	for _, jc := range cfg.Jobs {
+		if jc.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cronConfigParser is used to validate the schedules in the config file. Seconds
+// are optional, so that configs for both the standard and the seconds
+// based cron instances are accepted.
+var cronConfigParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// ParseCronConfig parses the config from the data. YAML is used if the
+// path has a .yaml or .yml extension, otherwise JSON is expected.
+func ParseCronConfig(path string, data []byte) (*CronConfig, error) {
+	var cfg CronConfig
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse yaml config: %v", err)
+		}
+	default:
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse json config: %v", err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// LoadConfig reads the jobs from the file and applies them to the scheduler.
+// Can be called multiple times, see ApplyConfig for the reload logic.
+func (s *CronScheduler) LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := ParseCronConfig(path, data)
+	if err != nil {
+		return err
+	}
+
+	return s.ApplyConfig(cfg)
+}
+
+// ApplyConfig validates all of the jobs in the config and reports every
+// problem at once. If the config is valid, the difference between the
+// current and the previous config is applied: new jobs are registered,
+// changed jobs are rescheduled and jobs that are no longer in the config
+// are removed. Jobs registered in code are not modified.
+func (s *CronScheduler) ApplyConfig(cfg *CronConfig) error {
+	if s == nil {
+		return fmt.Errorf("cron scheduler cannot be nil")
+	}
+
+	if cfg == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	eg := NewErrGroup(ErrGroupProps{ID: "invalid cron config"})
+	jobs := make([]*Job, 0, len(cfg.Jobs))
+	names := make(map[string]bool, len(cfg.Jobs))
+
+	for i := range cfg.Jobs {
+		jc := cfg.Jobs[i]
+
+		job := s.jobFromConfig(jc, eg)
+		if job == nil {
+			continue
+		}
+
+		if names[jc.Name] {
+			eg.Add(fmt.Errorf("job %v: name is used more than once", jc.Name))
+			continue
+		}
+		names[jc.Name] = true
+
+		if existing := s.findJob(jc.Name); existing != nil && existing.config == nil {
+			eg.Add(fmt.Errorf("job %v: already registered outside of the config", jc.Name))
+			continue
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	// check for cycles between the jobs registered in code and the new config
+	deps := make(map[string][]string)
+	for _, job := range s.Jobs {
+		if job != nil && job.config == nil {
+			deps[job.Name] = job.DependsOn
+		}
+	}
+
+	for _, job := range jobs {
+		deps[job.Name] = job.DependsOn
+	}
+
+	for _, job := range jobs {
+		eg.Add(findDependencyCycle(deps, job.Name))
+	}
+
+	// the dependencies have to be in the config or registered in code, the
+	// jobs with an invalid config are already reported above
+	for _, job := range jobs {
+		for _, dep := range job.DependsOn {
+			if _, ok := deps[dep]; !ok && !cfg.hasJob(dep) {
+				eg.Add(fmt.Errorf("job %v: unknown dependency %v", job.Name, dep))
+			}
+		}
+	}
+
+	if err := eg.ToErr(); err != nil {
+		return err
+	}
+
+	applyErrs := NewErrGroup(ErrGroupProps{ID: "failed to apply cron config"})
+
+	// remove the jobs which are no longer in the config, the changed jobs
+	// are replaced with the new definition below
+	for _, existing := range append([]*Job{}, s.Jobs...) {
+		if existing != nil && existing.config != nil && !names[existing.Name] {
+			applyErrs.Add(s.remove(existing.Name))
+		}
+	}
+
+	for _, job := range jobs {
+		existing := s.findJob(job.Name)
+		switch {
+		case existing == nil:
+			applyErrs.Add(s.register(job))
+		case !reflect.DeepEqual(existing.config, job.config):
+			applyErrs.Add(s.replace(existing, job))
+		}
+	}
+
+	return applyErrs.ToErr()
+}
+
+// replace reschedules the registered job with the new definition. If the
+// new job cannot be registered (e.g. the schedule is not supported by the
+// cron instance), the previous job is registered again, so that the job
+// is not lost from the scheduler.
+func (s *CronScheduler) replace(existing, job *Job) error {
+	removeErr := s.remove(existing.Name)
+	if s.findJob(existing.Name) != nil {
+		return removeErr
+	}
+
+	if err := s.register(job); err != nil {
+		if restoreErr := s.register(existing); restoreErr != nil {
+			return fmt.Errorf("job %v: %v (failed to restore the previous definition: %v)", job.Name, err, restoreErr)
+		}
+
+		return fmt.Errorf("job %v: %v (kept the previous definition)", job.Name, err)
+	}
+
+	return nil
+}
+
+// jobFromConfig validates the config and converts it to a Job. All of the
+// problems with the config are added to the ErrGroup, in which case
+// nil is returned.
+func (s *CronScheduler) jobFromConfig(jc JobConfig, eg *ErrGroup) *Job {
+	numErrs := eg.Len()
+	add := func(format string, a ...any) {
+		eg.Add(fmt.Errorf("job %v: "+format, append([]any{jc.Name}, a...)...))
+	}
+
+	if jc.Name == "" {
+		add("name has to be specified")
+	}
+
+	if jc.Schedule == "" {
+		if len(jc.DependsOn) == 0 {
+			add("schedule or depends_on has to be specified")
+		}
+	} else if _, err := cronConfigParser.Parse(jc.Schedule); err != nil {
+		add("invalid schedule %q: %v", jc.Schedule, err)
+	}
+
+	key := jc.Func
+	if key == "" {
+		key = jc.Name
+	}
+
+	fn, ok := s.Registry.Get(key)
+	if !ok {
+		add("function %q is not in the registry", key)
+	}
+
+	timeout, err := parseConfigDuration(jc.Timeout)
+	if err != nil {
+		add("invalid timeout: %v", err)
+	}
+
+	retryDelay, err := parseConfigDuration(jc.RetryDelay)
+	if err != nil {
+		add("invalid retry_delay: %v", err)
+	}
+
+	if jc.Retries < 0 {
+		add("retries cannot be negative")
+	}
+
+	if eg.Len() > numErrs {
+		return nil
+	}
+
+	return &Job{
+		Name:             jc.Name,
+		Schedule:         jc.Schedule,
+		Description:      jc.Description,
+		FuncCtx:          fn,
+		Timeout:          timeout,
+		Retries:          jc.Retries,
+		RetryDelay:       retryDelay,
+		AllowOverlap:     jc.AllowOverlap,
+		Tags:             jc.Tags,
+		ConcurrencyGroup: jc.Group,
+		DependsOn:        jc.DependsOn,
+		config:           &jc,
+	}
+}
+
+// findJob returns the registered job with the provided name or nil.
+func (s *CronScheduler) findJob(name string) *Job { return findJobIn(s.Jobs, name) }
+
+func findJobIn(jobs []*Job, name string) *Job {
+	for _, job := range jobs {
+		if job != nil && job.Name == name {
+			return job
+		}
+	}
+
+	return nil
+}
+
+func parseConfigDuration(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("duration cannot be negative")
+	}
+
+	return d, nil
+}
@@ -0,0 +1,235 @@
+package syro
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jobRun is a single execution of a job and of the jobs which were triggered
+// by it. A new run is started each time a job is executed by the cron
+// schedule, the dependent jobs inherit the run of the upstream jobs which
+// triggered them (see finishRun).
+type jobRun struct {
+	id string
+}
+
+func newJobRun() *jobRun {
+	return &jobRun{id: newRandomID()}
+}
+
+// jobFanIn stores the latest results of the upstream jobs for each of the
+// dependent jobs, since the dependent job was last triggered. Because the
+// results are not tied to a run, the upstream jobs can be scheduled
+// independently of each other.
+type jobFanIn struct {
+	mu      sync.Mutex
+	results map[string]map[string]fanInResult // dependent -> upstream -> result
+}
+
+// fanInResult is the result of the upstream job and the run it was a part of.
+type fanInResult struct {
+	success bool
+	runID   string
+}
+
+// record stores the result of the upstream job for the dependent job. Once
+// all of the dependencies have a result, the results of the dependent
+// are reset and ready is true. ok is false if the latest result of any
+// of the dependencies was not a success. shared is true if all of the
+// results are from the same run (e.g. the upstream jobs are a part of
+// the same chain).
+func (f *jobFanIn) record(dependent *Job, upstream string, run *jobRun, success bool) (ready, ok, shared bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.results == nil {
+		f.results = make(map[string]map[string]fanInResult)
+	}
+
+	results := f.results[dependent.Name]
+	if results == nil {
+		results = make(map[string]fanInResult)
+		f.results[dependent.Name] = results
+	}
+	results[upstream] = fanInResult{success: success, runID: run.id}
+
+	ok, shared = true, true
+	for _, dep := range dependent.DependsOn {
+		result, finished := results[dep]
+		if !finished {
+			return false, false, false
+		}
+
+		ok = ok && result.success
+		shared = shared && result.runID == run.id
+	}
+
+	delete(f.results, dependent.Name)
+	return true, ok, shared
+}
+
+// reset removes the results of the dependent job, used when the job is removed.
+func (f *jobFanIn) reset(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.results, name)
+}
+
+// finishRun records the result of the job for the jobs which depend on it
+// and triggers the ones whose dependencies have all finished. If one of
+// the dependencies did not succeed, the dependent job (and the jobs that
+// depend on it) are skipped.
+//
+// The dependent job inherits the run if all of its dependencies finished
+// as a part of it. If the dependencies finished in different runs (e.g.
+// they are scheduled independently), a new run is started instead.
+func (s *CronScheduler) finishRun(j *Job, run *jobRun, success bool) {
+	for _, dependent := range s.dependents(j.Name) {
+		ready, ok, shared := s.fanIn.record(dependent, j.Name, run, success)
+		if !ready {
+			continue
+		}
+
+		next := run
+		if !shared {
+			next = newJobRun()
+		}
+
+		if ok {
+			go s.trigger(dependent, next)
+		} else {
+			s.skipExecution(dependent, next, JobStatusSkipped, "upstream job failed")
+		}
+	}
+}
+
+// trigger executes the dependent job as a part of the run, respecting the
+// overlap lock of the job. If the previous run of the job is not
+// finished, the job is skipped.
+func (s *CronScheduler) trigger(j *Job, run *jobRun) {
+	if j.lock == nil {
+		s.execute(j, run)
+		return
+	}
+
+	if !j.lock.run(func() { s.execute(j, run) }) {
+		s.skipExecution(j, run, JobStatusBusy, "previous run is not finished")
+	}
+}
+
+// skipExecution registers the execution of the job which was not run with
+// the status and the reason, and finishes the run of the job as failed,
+// so that the jobs which depend on it are skipped as well.
+func (s *CronScheduler) skipExecution(j *Job, run *jobRun, status JobStatus, reason string) {
+	if s.CronStorage != nil {
+		now := time.Now().UTC()
+		ex := &CronExecLog{
+			Source:        s.Source,
+			Name:          j.Name,
+			RunID:         run.id,
+			Status:        status,
+			InitializedAt: now,
+			FinishedAt:    now,
+			Error:         reason,
+		}
+
+		if err := s.CronStorage.RegisterExecution(ex); err != nil {
+			j.logStorageErr(run, "failed to register execution", s.Source, err)
+		}
+
+		if err := s.CronStorage.RegisterJob(s.Source, j.Name, j.Schedule, j.Description, j.Tags, status, nil); err != nil {
+			j.logStorageErr(run, "failed to set job to "+string(status), s.Source, err)
+		}
+	}
+
+	s.finishRun(j, run, false)
+}
+
+// dependents returns the registered jobs which depend on the job with the
+// provided name.
+func (s *CronScheduler) dependents(name string) []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []*Job
+	for _, job := range s.Jobs {
+		if job != nil && slices.Contains(job.DependsOn, name) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
+
+// dependencyGraph returns the dependencies of the registered jobs.
+func (s *CronScheduler) dependencyGraph() map[string][]string {
+	deps := make(map[string][]string, len(s.Jobs))
+	for _, job := range s.Jobs {
+		if job != nil {
+			deps[job.Name] = job.DependsOn
+		}
+	}
+
+	return deps
+}
+
+// findDependencyCycle returns an error if the job with the provided name is a
+// part of a cycle in the dependency graph. Dependencies which are not in
+// the graph are ignored, because they can be registered later.
+func findDependencyCycle(deps map[string][]string, name string) error {
+	visited := make(map[string]bool)
+
+	var visit func(current string, path []string) error
+	visit = func(current string, path []string) error {
+		path = append(path, current)
+
+		if current == name && len(path) > 1 {
+			return fmt.Errorf("dependency cycle: %v", strings.Join(path, " -> "))
+		}
+
+		if visited[current] {
+			return nil
+		}
+		visited[current] = true
+
+		for _, dep := range deps[current] {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return visit(name, nil)
+}
+
+type jobRunIDKey struct{}
+
+func contextWithJobRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, jobRunIDKey{}, id)
+}
+
+// JobRunID returns the ID of the run which is shared by all of the jobs in
+// the same dependency chain. Can be used in the FuncCtx of the job.
+func JobRunID(ctx context.Context) string {
+	id, _ := ctx.Value(jobRunIDKey{}).(string)
+	return id
+}
+
+// newRandomID returns a random hex encoded string.
+func newRandomID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
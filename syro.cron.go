@@ -1,6 +1,7 @@
 package syro
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -12,21 +13,32 @@ import (
 // registration of jobs and the optional storage of job status and
 // execution logs using the CronStorage interface.
 type CronScheduler struct {
-	cron        *cron.Cron  // cron is the cron CronScheduler which will run the jobs
-	Source      string      // Source is used to identify the source of the job
-	Jobs        []*Job      // Jobs is a list of all registered jobs
-	CronStorage CronStorage // Storage is an optional storage interface for the CronScheduler
+	cron        *cron.Cron   // cron is the cron CronScheduler which will run the jobs
+	Source      string       // Source is used to identify the source of the job
+	Jobs        []*Job       // Jobs is a list of all registered jobs
+	CronStorage CronStorage  // Storage is an optional storage interface for the CronScheduler
+	Registry    *JobRegistry // Registry is an optional list of functions which can be used by the jobs in the config file
+	mu          sync.Mutex   // mu guards the Jobs list
+
+	MaxConcurrent int            // Optional. Max number of jobs that can run at the same time, 0 means no limit
+	GroupLimits   map[string]int // Optional. Max number of jobs that can run at the same time for each Job.ConcurrencyGroup
+	ThrottleWait  time.Duration  // Optional. How long a job waits for a free slot before it is skipped as throttled
+	limits        *jobLimiter    // limits is created on the first run, using the values above
+	limitsOnce    sync.Once
+	fanIn         jobFanIn // latest results of the upstream jobs of the dependent jobs
 }
 
 type CronStorage interface {
-	// FindCronJobs returns a list of all registered jobs
-	FindCronJobs() ([]CronJob, error)
+	// FindCronJobs returns a list of registered jobs that match the filter
+	FindCronJobs(filter CronJobFilter, maxLimit int64) ([]CronJob, error)
 	// RegisterJob registers the details of the selected job
-	RegisterJob(source, name, sched, descr string, status JobStatus, err error) error
+	RegisterJob(source, name, sched, descr string, tags []string, status JobStatus, err error) error
 	// RegisterExecution registers the execution of a job if the storage is specified
 	RegisterExecution(*CronExecLog) error
 	// FindExecutions returns a list of job executions that match the filter
-	FindExecutions(CronExecFilter) ([]CronExecLog, error)
+	FindExecutions(filter CronExecFilter, maxLimit int64) ([]CronExecLog, error)
+	// FindExecutionsPage returns a page of the job executions that match the filter, with the cursor of the next page
+	FindExecutionsPage(filter CronExecFilter, maxLimit int64) (*Page[CronExecLog], error)
 	// SetJobsToInactive updates the status of the jobs for the given source. Useful when the app exits.
 	SetJobsToInactive(source string) error
 }
@@ -41,40 +53,81 @@ func (s *CronScheduler) WithStorage(storage CronStorage) *CronScheduler {
 	return s
 }
 
+// WithMaxConcurrent sets the max number of jobs that can run at the same
+// time. Should be called before Start.
+func (s *CronScheduler) WithMaxConcurrent(n int) *CronScheduler {
+	s.MaxConcurrent = n
+	return s
+}
+
+// WithGroupLimit sets the max number of jobs with the provided
+// ConcurrencyGroup that can run at the same time. Should be
+// called before Start.
+func (s *CronScheduler) WithGroupLimit(group string, n int) *CronScheduler {
+	if s.GroupLimits == nil {
+		s.GroupLimits = make(map[string]int)
+	}
+
+	s.GroupLimits[group] = n
+	return s
+}
+
+// WithThrottleWait sets for how long a job waits for a free slot if one of
+// the concurrency limits is reached. If the wait is 0, the job is
+// skipped right away. Should be called before Start.
+func (s *CronScheduler) WithThrottleWait(d time.Duration) *CronScheduler {
+	s.ThrottleWait = d
+	return s
+}
+
+// WithRegistry sets the registry of job functions which are used when
+// loading the jobs from a config file.
+func (s *CronScheduler) WithRegistry(registry *JobRegistry) *CronScheduler {
+	s.Registry = registry
+	return s
+}
+
 // Register adds a new job to the cron CronScheduler and wraps the job function with a
 // mutex lock to prevent the execution of the job if it is already running.
 // If a storage interface is provided, the job and job execution logs
 // will be stored using it
 func (s *CronScheduler) Register(j *Job) error {
-	if j == nil {
-		return fmt.Errorf("job cannot be nil")
-	}
-
 	if s == nil {
 		return fmt.Errorf("cron scheduler cannot be nil")
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.register(j)
+}
+
+// register is the unlocked implementation of Register.
+func (s *CronScheduler) register(j *Job) error {
+	if j == nil {
+		return fmt.Errorf("job cannot be nil")
+	}
+
 	if s.cron == nil {
 		return fmt.Errorf("cron cannot be nil")
 	}
 
-	name := j.Name
-	schedule := j.Schedule
-	source := s.Source
-	descr := j.Description
-
-	if schedule == "" {
-		return fmt.Errorf("schedule has to be specified")
+	if j.Schedule == "" && len(j.DependsOn) == 0 {
+		return fmt.Errorf("schedule or dependencies have to be specified")
 	}
 
-	if name == "" {
+	if j.Name == "" {
 		return fmt.Errorf("name has to be specified")
 	}
 
-	if j.Func == nil {
+	if j.Func == nil && j.FuncCtx == nil {
 		return fmt.Errorf("job function cannot be nil")
 	}
 
+	if j.Retries < 0 {
+		return fmt.Errorf("retries cannot be negative")
+	}
+
 	// if the name of the job is already taken, return an error
 	for _, job := range s.Jobs {
 		if job == nil {
@@ -86,79 +139,179 @@ func (s *CronScheduler) Register(j *Job) error {
 		}
 	}
 
-	storageSpecified := s.CronStorage != nil
-	loggerSpecified := j.Logger != nil
+	deps := s.dependencyGraph()
+	deps[j.Name] = j.DependsOn
+	if err := findDependencyCycle(deps, j.Name); err != nil {
+		return err
+	}
 
 	// NOTE: there is a slight inefficiency in the data that is written by
 	// the query because the (source, name, schedule, descr) params are
 	// written each time in order to update the status.
 
-	if storageSpecified {
-		if err := s.CronStorage.RegisterJob(source, name, schedule, descr, JobStatusInitialized, nil); err != nil {
+	if s.CronStorage != nil {
+		if err := s.CronStorage.RegisterJob(s.Source, j.Name, j.Schedule, j.Description, j.Tags, JobStatusInitialized, nil); err != nil {
 			return err
 		}
 	}
 
-	joblock := newJobLock(func() {
+	j.lock = nil
+	if !j.AllowOverlap {
+		j.lock = newJobLock(func() { s.execute(j, nil) }, j.Name)
+	}
 
-		jobStart := time.Now()
-		// Accumulate errors in the c.AddJob function, because the cron.Job param does not return anything
+	// jobs without a schedule are only triggered by the jobs they depend on
+	if j.Schedule != "" {
+		var cronJob cron.Job = cron.FuncJob(func() { s.execute(j, nil) })
+		if j.lock != nil {
+			cronJob = j.lock
+		}
 
-		if storageSpecified {
-			if err := s.CronStorage.RegisterJob(s.Source, name, schedule, descr, JobStatusRunning, nil); err != nil {
-				if loggerSpecified {
-					j.Logger.Error("failed to set job to running", LogFields{
-						"source": source,
-						"name":   name,
-						"error":  err.Error(),
-					})
-				}
-			}
+		id, err := s.cron.AddJob(j.Schedule, cronJob)
+		if err != nil {
+			return err
 		}
 
-		// Passed in job function which should be executed by the cron job
-		jobErr := j.Func()
+		j.entryID = id
+	}
+
+	// Add the job to the list of registered jobs
+	s.Jobs = append(s.Jobs, j)
+
+	return nil
+}
+
+// Remove stops the scheduling of the job with the provided name and removes it
+// from the list of registered jobs. If a storage interface is provided, the
+// status of the job is set to removed.
+func (s *CronScheduler) Remove(name string) error {
+	if s == nil {
+		return fmt.Errorf("cron scheduler cannot be nil")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		if j.OnComplete != nil {
-			j.OnComplete(jobErr)
+	return s.remove(name)
+}
+
+// remove is the unlocked implementation of Remove.
+func (s *CronScheduler) remove(name string) error {
+	for i, job := range s.Jobs {
+		if job == nil || job.Name != name {
+			continue
 		}
 
-		if jobErr != nil && j.OnError != nil {
-			j.OnError(jobErr)
+		if job.entryID != 0 {
+			s.cron.Remove(job.entryID)
 		}
+		s.Jobs = append(s.Jobs[:i], s.Jobs[i+1:]...)
+		s.fanIn.reset(name)
 
-		if storageSpecified {
-			if err := s.CronStorage.RegisterExecution(newCronExecutionLog(source, name, jobStart, jobErr)); err != nil {
-				if loggerSpecified {
-					j.Logger.Error("failed to register execution", LogFields{
-						"source": source,
-						"name":   name,
-						"error":  err.Error(),
-					})
-				}
-			}
-
-			if err := s.CronStorage.RegisterJob(s.Source, name, schedule, descr, JobStatusDone, jobErr); err != nil {
-				if loggerSpecified {
-					j.Logger.Error("failed to set job to done", LogFields{
-						"source": source,
-						"name":   name,
-						"error":  err.Error(),
-					})
-				}
-			}
+		if s.CronStorage != nil {
+			return s.CronStorage.RegisterJob(s.Source, job.Name, job.Schedule, job.Description, job.Tags, JobStatusRemoved, nil)
 		}
 
-	}, name)
+		return nil
+	}
 
-	if _, err := s.cron.AddJob(schedule, joblock); err != nil {
-		return err
+	return fmt.Errorf("job with name %v does not exist", name)
+}
+
+// execute runs the job function (with the optional retries) and stores the
+// status of the job and the execution log if the storage is specified.
+// If the run is nil, a new run is started, otherwise the job is
+// executed as a part of the dependency chain of the run.
+func (s *CronScheduler) execute(j *Job, run *jobRun) {
+	if run == nil {
+		run = newJobRun()
 	}
 
-	// Add the job to the list of registered jobs
-	s.Jobs = append(s.Jobs, j)
+	source := s.Source
+	name := j.Name
+	schedule := j.Schedule
+	descr := j.Description
 
-	return nil
+	storageSpecified := s.CronStorage != nil
+
+	s.limitsOnce.Do(func() { s.limits = newJobLimiter(s.MaxConcurrent, s.GroupLimits) })
+
+	release, ok := s.limits.acquire(j.ConcurrencyGroup, s.ThrottleWait)
+	if !ok {
+		if j.Logger != nil {
+			j.Logger.WithEventID(run.id).Warn("job throttled", LogFields{
+				"source": source,
+				"name":   name,
+				"group":  j.ConcurrencyGroup,
+			})
+		}
+
+		s.skipExecution(j, run, JobStatusThrottled, "concurrency limit reached")
+		return
+	}
+
+	jobStart := time.Now()
+	// Accumulate errors in the c.AddJob function, because the cron.Job param does not return anything
+
+	if storageSpecified {
+		if err := s.CronStorage.RegisterJob(source, name, schedule, descr, j.Tags, JobStatusRunning, nil); err != nil {
+			j.logStorageErr(run, "failed to set job to running", source, err)
+		}
+	}
+
+	// Passed in job function which should be executed by the cron job
+	jobErr := j.run(s.jobContext(j, run))
+
+	if j.OnComplete != nil {
+		j.OnComplete(jobErr)
+	}
+
+	if jobErr != nil && j.OnError != nil {
+		j.OnError(jobErr)
+	}
+
+	if storageSpecified {
+		if err := s.CronStorage.RegisterExecution(newCronExecutionLog(source, name, run.id, jobStart, jobErr)); err != nil {
+			j.logStorageErr(run, "failed to register execution", source, err)
+		}
+
+		if err := s.CronStorage.RegisterJob(source, name, schedule, descr, j.Tags, JobStatusDone, jobErr); err != nil {
+			j.logStorageErr(run, "failed to set job to done", source, err)
+		}
+	}
+
+	// release the concurrency slots before the dependent jobs are triggered
+	release()
+	s.finishRun(j, run, jobErr == nil)
+}
+
+// jobContext returns the context of the job function. The id of the run is
+// used as the event ID, so that the logs of the job (and of the jobs in
+// the same dependency chain) can be correlated with the executions.
+func (s *CronScheduler) jobContext(j *Job, run *jobRun) context.Context {
+	ctx := contextWithJobRunID(context.Background(), run.id)
+	ctx = ContextWithEventID(ctx, run.id)
+
+	if j.Logger != nil {
+		ctx = ContextWithLogger(ctx, j.Logger)
+	}
+
+	return ctx
+}
+
+// logStorageErr logs the error of the storage if the logger of the job is
+// specified. The id of the run is used as the event ID, same as for the
+// logs of the job function.
+func (j *Job) logStorageErr(run *jobRun, msg, source string, err error) {
+	if j.Logger == nil {
+		return
+	}
+
+	j.Logger.WithEventID(run.id).Error(msg, LogFields{
+		"source": source,
+		"name":   j.Name,
+		"error":  err.Error(),
+	})
 }
 
 // Start the cron CronScheduler.
@@ -173,17 +326,59 @@ func (s *CronScheduler) Start() {
 
 // Job represents a cron job that can be registered with the CronScheduler.
 // TODO: test callbacks
-// TODO: add a context input for callbacks? so that it would be possible to optionally cancel the job if it takes longer than x to run
-// TODO: add retrys logic? + additional pause between them?
 type Job struct {
-	Source      string       // Source of the job (like the name of application which registered the job)
-	Schedule    string       // Schedule of the job (e.g. "0 0 * * *" or "@every 1h")
-	Name        string       // Name of the job
-	Func        func() error // Function to be executed by the job
-	Description string       // Optional. Description of the job
-	OnError     func(error)  // Optional. Function to be executed if the job returns an error
-	OnComplete  func(error)  // Optional. Function to be executed when the job is completed.
-	Logger      Logger       // Optional. Used to log the errors for the cron registration
+	Source           string                          // Source of the job (like the name of application which registered the job)
+	Schedule         string                          // Schedule of the job (e.g. "0 0 * * *" or "@every 1h")
+	Name             string                          // Name of the job
+	Func             func() error                    // Function to be executed by the job
+	FuncCtx          func(ctx context.Context) error // Optional. Context aware version of Func, used instead of it if specified
+	Description      string                          // Optional. Description of the job
+	OnError          func(error)                     // Optional. Function to be executed if the job returns an error
+	OnComplete       func(error)                     // Optional. Function to be executed when the job is completed.
+	Logger           Logger                          // Optional. Used to log the errors for the cron registration, available in FuncCtx with FromContext
+	Timeout          time.Duration                   // Optional. Cancels the context of FuncCtx if a single run takes longer than this
+	Retries          int                             // Optional. Number of times the function is retried if it returns an error
+	RetryDelay       time.Duration                   // Optional. Pause between the retries
+	AllowOverlap     bool                            // Optional. Allow the job to start even if the previous run is not finished
+	Tags             []string                        // Optional. Tags used for grouping the jobs
+	ConcurrencyGroup string                          // Optional. Name of the group that is limited by CronScheduler.GroupLimits
+	DependsOn        []string                        // Optional. Names of the jobs which trigger this job once all of them have finished since its last run
+
+	entryID cron.EntryID // id of the job in the cron scheduler, used for removing the job
+	config  *JobConfig   // config from which the job was created, nil if it was registered in code
+	lock    *jobLock     // lock which prevents overlapping runs, nil if AllowOverlap is true
+}
+
+// run executes the job function, retrying it if it returns an error. The
+// Timeout is applied to each of the attempts separately.
+func (j *Job) run(ctx context.Context) error {
+	var err error
+
+	for attempt := 0; attempt <= j.Retries; attempt++ {
+		if attempt > 0 && j.RetryDelay > 0 {
+			time.Sleep(j.RetryDelay)
+		}
+
+		if err = j.runOnce(ctx); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (j *Job) runOnce(ctx context.Context) error {
+	if j.FuncCtx == nil {
+		return j.Func()
+	}
+
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	return j.FuncCtx(ctx)
 }
 
 // CronJob stores information about the registered job
@@ -199,12 +394,27 @@ type CronJob struct {
 	Description string     `json:"descr" bson:"descr"`
 	Error       string     `json:"error" bson:"error"`
 	ExitWithErr bool       `json:"exit_with_err" bson:"exit_with_err"`
+	Tags        []string   `json:"tags" bson:"tags"`
+}
+
+// CronJobFilter is used to filter the registered jobs. The From and To
+// fields of the TimeseriesFilter are applied to the updated_at field.
+type CronJobFilter struct {
+	TimeseriesFilter `json:"timeseries_filter" bson:"timeseries_filter"`
+	Source           string    `json:"source" bson:"source"`
+	Name             string    `json:"name" bson:"name"`                   // Regex pattern of the job name
+	Status           JobStatus `json:"status" bson:"status"`               // Exact status of the job
+	Tags             []string  `json:"tags" bson:"tags"`                   // Jobs which have all of the tags
+	ExitWithErr      *bool     `json:"exit_with_err" bson:"exit_with_err"` // Optional. Only jobs which did or did not exit with an error
 }
 
 // CronExecLog stores information about the job execution
 type CronExecLog struct {
+	ID            string        `json:"_id" bson:"_id,omitempty"`
 	Source        string        `json:"source" bson:"source"`
 	Name          string        `json:"name" bson:"name"`
+	RunID         string        `json:"run_id" bson:"run_id"` // ID shared by all of the executions in the dependency chain
+	Status        JobStatus     `json:"status" bson:"status"` // Either done, throttled or one of the skip statuses
 	InitializedAt time.Time     `json:"initialized_at" bson:"initialized_at"`
 	FinishedAt    time.Time     `json:"finished_at" bson:"finished_at"`
 	ExecutionTime time.Duration `json:"execution_time" bson:"execution_time"`
@@ -218,10 +428,12 @@ type CronExecFilter struct {
 	ExecutionTime    time.Duration `json:"execution_time" bson:"execution_time"`
 }
 
-func newCronExecutionLog(source, name string, initializedAt time.Time, err error) *CronExecLog {
+func newCronExecutionLog(source, name, runID string, initializedAt time.Time, err error) *CronExecLog {
 	log := &CronExecLog{
 		Source:        source,
 		Name:          name,
+		RunID:         runID,
+		Status:        JobStatusDone,
 		InitializedAt: initializedAt,
 		FinishedAt:    time.Now().UTC(),
 		ExecutionTime: time.Since(initializedAt),
@@ -238,11 +450,14 @@ func newCronExecutionLog(source, name string, initializedAt time.Time, err error
 type JobStatus string
 
 const (
-	JobStatusInitialized JobStatus = "initialized" // status set when the cron is added, but has not been run yet
-	JobStatusRunning     JobStatus = "running"     // crons which are currently running
-	JobStatusDone        JobStatus = "done"        // crons which are finished
-	JobStatusInactive    JobStatus = "inactive"    // crons which are not running
-	JobStatusRemoved     JobStatus = "removed"     // crons which are not present in the current list for the source
+	JobStatusInitialized JobStatus = "initialized"          // status set when the cron is added, but has not been run yet
+	JobStatusRunning     JobStatus = "running"              // crons which are currently running
+	JobStatusDone        JobStatus = "done"                 // crons which are finished
+	JobStatusInactive    JobStatus = "inactive"             // crons which are not running
+	JobStatusRemoved     JobStatus = "removed"              // crons which are not present in the current list for the source
+	JobStatusThrottled   JobStatus = "throttled"            // crons which were skipped because of the concurrency limits
+	JobStatusSkipped     JobStatus = "skip-upstream-failed" // crons which were skipped because one of the dependencies failed
+	JobStatusBusy        JobStatus = "skip-already-running" // dependent crons which were skipped because the previous run was not finished
 )
 
 // jobLock is a mutex lock that prevents the execution of a job if it is already running.
@@ -256,11 +471,100 @@ func newJobLock(jobFunc func(), name string) *jobLock {
 	return &jobLock{name: name, fn: jobFunc}
 }
 
-func (j *jobLock) Run() {
-	if j.mu.TryLock() {
-		defer j.mu.Unlock()
-		j.fn()
-	} else {
+func (j *jobLock) Run() { j.run(j.fn) }
+
+// run executes the provided function instead of the default one, if the
+// job is not already running. Returns false if the function was skipped.
+func (j *jobLock) run(fn func()) bool {
+	if !j.mu.TryLock() {
 		fmt.Printf("job %v already running. Skipping...\n", j.name)
+		return false
+	}
+
+	defer j.mu.Unlock()
+	fn()
+	return true
+}
+
+// jobLimiter limits the number of jobs that can run at the same time, both
+// globally and for each concurrency group. Unlike the jobLock, which only
+// prevents a job from overlapping with itself, the limits are shared
+// between all of the jobs of the CronScheduler.
+type jobLimiter struct {
+	global chan struct{}
+	groups map[string]chan struct{}
+}
+
+func newJobLimiter(maxConcurrent int, groupLimits map[string]int) *jobLimiter {
+	l := &jobLimiter{groups: make(map[string]chan struct{})}
+
+	if maxConcurrent > 0 {
+		l.global = make(chan struct{}, maxConcurrent)
+	}
+
+	for group, limit := range groupLimits {
+		if limit > 0 {
+			l.groups[group] = make(chan struct{}, limit)
+		}
+	}
+
+	return l
+}
+
+// acquire takes a slot from the group and the global limit, waiting for at
+// most the provided duration. The group slot is taken first, so that a
+// job which waits for its group does not hold a global slot which could
+// be used by the jobs of the other groups. If the slots were acquired,
+// the returned function has to be called to release them.
+func (l *jobLimiter) acquire(group string, wait time.Duration) (func(), bool) {
+	var deadline <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	sems := []chan struct{}{l.groups[group], l.global}
+	acquired := make([]chan struct{}, 0, len(sems))
+
+	release := func() {
+		for _, sem := range acquired {
+			<-sem
+		}
+	}
+
+	for _, sem := range sems {
+		if sem == nil {
+			continue
+		}
+
+		if !acquireSlot(sem, deadline) {
+			release()
+			return nil, false
+		}
+
+		acquired = append(acquired, sem)
+	}
+
+	return release, true
+}
+
+// acquireSlot tries to send to the semaphore until the deadline is reached.
+// If the deadline is nil, the send is not blocking.
+func acquireSlot(sem chan struct{}, deadline <-chan time.Time) bool {
+	if deadline == nil {
+		select {
+		case sem <- struct{}{}:
+			return true
+		default:
+			return false
+		}
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-deadline:
+		return false
 	}
 }
@@ -2,7 +2,8 @@ package syro
 
 import (
 	"fmt"
-	"strings"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -19,20 +20,30 @@ type Logger interface {
 	FindLogs(filter LogFilter, maxLimit int64) ([]Log, error) // FindLogs returns the logs that match the provided filter
 	LogExists(filter any) (bool, error)                       // LogExists checks if the log with the provided filter exists.
 	GetProps() LoggerProps                                    // GetProps returns the properties of the logger
-	WithSource(v string) Logger                               // WithSource sets the source of the log
-	WithEvent(v string) Logger                                // WithEvent sets the event of the log
-	WithEventID(v string) Logger                              // WithEventID sets the event id of the log
+	WithSource(v string) Logger                               // WithSource returns a copy of the logger with the source of the log
+	WithEvent(v string) Logger                                // WithEvent returns a copy of the logger with the event of the log
+	WithEventID(v string) Logger                              // WithEventID returns a copy of the logger with the event id of the log
+	With(fields LogFields) Logger                             // With returns a copy of the logger with fields that are added to every log
+}
+
+// LogWriter is implemented by the loggers which can store already created
+// logs in a single batch (e.g. the logs received from other services).
+// The logs keep their own properties and timestamps.
+type LogWriter interface {
+	WriteLogs(logs []Log) error
 }
 
 type Log struct {
-	Timestamp time.Time `json:"timestamp" bson:"timestamp"`                   // Time of the log (UTC)
-	ID        string    `json:"_id" bson:"_id"`                               // (not logged to the console)
-	Message   string    `json:"message" bson:"message"`                       // Logged message
-	Source    string    `json:"source,omitempty" bson:"source,omitempty"`     // Source of the log (api, pooler, etc.)
-	Event     string    `json:"event,omitempty" bson:"event,omitempty"`       // Event of the log (api-auth-request, binance-eth-pooler, etc.)
-	EventID   string    `json:"event_id,omitempty" bson:"event_id,omitempty"` // (not logged to the console)
-	Fields    LogFields `json:"fields,omitempty" bson:"fields,omitempty"`     // Optional fields
-	Level     LogLevel  `json:"level" bson:"level"`                           // Log level
+	Timestamp time.Time  `json:"timestamp" bson:"timestamp"`                   // Time of the log (UTC)
+	ID        string     `json:"_id" bson:"_id"`                               // (not logged to the console)
+	Message   string     `json:"message" bson:"message"`                       // Logged message
+	Source    string     `json:"source,omitempty" bson:"source,omitempty"`     // Source of the log (api, pooler, etc.)
+	Event     string     `json:"event,omitempty" bson:"event,omitempty"`       // Event of the log (api-auth-request, binance-eth-pooler, etc.)
+	EventID   string     `json:"event_id,omitempty" bson:"event_id,omitempty"` // (not logged to the console)
+	Fields    LogFields  `json:"fields,omitempty" bson:"fields,omitempty"`     // Optional fields
+	Caller    *LogCaller `json:"caller,omitempty" bson:"caller,omitempty"`     // Optional. Code which created the log
+	Stack     string     `json:"stack,omitempty" bson:"stack,omitempty"`       // Optional. Stack trace of the ERROR and FATAL logs
+	Level     LogLevel   `json:"level" bson:"level"`                           // Log level
 }
 
 type LogFields map[string]any
@@ -51,9 +62,80 @@ const (
 
 // LoggerSettings struct for storing the settings for the logger which are
 // used when printing the log to the console.
+//
+// Logs with a level below the MinLevel (or the level of the source in
+// SourceLevels) are dropped. Use the Set* methods to change the levels
+// while the logger is in use.
 type LoggerSettings struct {
-	Location   *time.Location
-	TimeFormat string
+	Location       *time.Location
+	TimeFormat     string
+	MinLevel       LogLevel            // Optional. Min level of the logs which are written, 0 means all levels
+	SourceLevels   map[string]LogLevel // Optional. Overrides the MinLevel for the logs of the source
+	DisableConsole bool                // Optional. Disables the console output of the loggers which store the logs (e.g. MongoLogger)
+	Redaction      *Redaction          // Optional. Removes the sensitive data from the logs before they are written
+	Format         ConsoleFormat       // Optional. Format of the console output, defaults to ConsoleText
+	ShowEventID    bool                // Optional. Prints the event id of the logs to the console
+	ShowCaller     bool                // Optional. Captures the caller of the logs and prints it to the console
+	CaptureCaller  bool                // Optional. Captures the caller of every log, which is stored with the log (not printed to the console)
+	CaptureStack   bool                // Optional. Captures the stack trace of the ERROR and FATAL logs
+	mu             sync.RWMutex        // mu guards the levels, so that they can be changed at runtime
+}
+
+// Enabled returns true if the log with the level and source should be
+// written. All levels are enabled if the settings are nil.
+func (s *LoggerSettings) Enabled(level LogLevel, source string) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	minLevel := s.MinLevel
+	if l, ok := s.SourceLevels[source]; ok {
+		minLevel = l
+	}
+
+	return level >= minLevel
+}
+
+// SetMinLevel changes the min level of the logs at runtime.
+func (s *LoggerSettings) SetMinLevel(level LogLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.MinLevel = level
+}
+
+// SetSourceLevel changes the min level of the logs for the source at runtime.
+func (s *LoggerSettings) SetSourceLevel(source string, level LogLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// copy the map, so that the map which was passed in is not modified
+	levels := make(map[string]LogLevel, len(s.SourceLevels)+1)
+	for k, v := range s.SourceLevels {
+		levels[k] = v
+	}
+	levels[source] = level
+
+	s.SourceLevels = levels
+}
+
+// RemoveSourceLevel removes the override of the source, so that the MinLevel
+// is used again.
+func (s *LoggerSettings) RemoveSourceLevel(source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	levels := make(map[string]LogLevel, len(s.SourceLevels))
+	for k, v := range s.SourceLevels {
+		if k != source {
+			levels[k] = v
+		}
+	}
+
+	s.SourceLevels = levels
 }
 
 const defaultTimeFormat = "2006-01-02 15:04:05"
@@ -63,15 +145,25 @@ const defaultTimeFormat = "2006-01-02 15:04:05"
 var DefaultLoggerSettings = &LoggerSettings{
 	Location:   time.UTC,
 	TimeFormat: defaultTimeFormat,
-	// TODO: optional disable for console?
 }
 
+// LogFilter is used to query the logs. The From and To of the time range
+// can be used separately. Source and Sources (or Event and Events) are
+// combined, so the log matches if it has any of the values.
 type LogFilter struct {
 	TimeseriesFilter `json:"timeseries_filter"`
 	Source           string    `json:"source"`
 	Event            string    `json:"event"`
 	EventID          string    `json:"event_id"`
 	Level            *LogLevel `json:"level"`
+	Sources          []string  `json:"sources,omitempty"`       // Optional. Match any of the sources
+	Events           []string  `json:"events,omitempty"`        // Optional. Match any of the events
+	MinLevel         *LogLevel `json:"min_level,omitempty"`     // Optional. Min level of the logs (inclusive)
+	MaxLevel         *LogLevel `json:"max_level,omitempty"`     // Optional. Max level of the logs (inclusive)
+	Message          string    `json:"message,omitempty"`       // Optional. Case insensitive substring of the message
+	MessageRegex     string    `json:"message_regex,omitempty"` // Optional. Regex which the message has to match
+	Caller           string    `json:"caller,omitempty"`        // Optional. Substring of the file or the function of the caller
+	Fields           LogFields `json:"fields,omitempty"`        // Optional. Values of the fields, nested keys are separated with a dot
 }
 
 func (l LogLevel) String() string {
@@ -93,6 +185,157 @@ func (l LogLevel) String() string {
 	}
 }
 
+// ParseLogLevel parses the name of the level (e.g. "info") or its number.
+func ParseLogLevel(v string) (LogLevel, error) {
+	for l := TRACE; l <= FATAL; l++ {
+		if v == l.String() {
+			return l, nil
+		}
+	}
+
+	if n, err := strconv.Atoi(v); err == nil && LogLevel(n) >= TRACE && LogLevel(n) <= FATAL {
+		return LogLevel(n), nil
+	}
+
+	return 0, fmt.Errorf("invalid log level: %s", v)
+}
+
+// mergeFields returns a new map with the default fields and the fields of
+// the log. The fields of the log override the default ones. Returns nil
+// if there are no fields.
+func mergeFields(defaults LogFields, lf ...LogFields) LogFields {
+	size := len(defaults)
+	for _, fields := range lf {
+		size += len(fields)
+	}
+
+	if size == 0 {
+		return nil
+	}
+
+	merged := make(LogFields, size)
+	for k, v := range defaults {
+		merged[k] = v
+	}
+
+	for _, fields := range lf {
+		for k, v := range fields {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
+
+// recordLog writes the log with the logger and returns the written log, so
+// that the wrappers of the loggers (e.g. the BroadcastLogger) use the same
+// log, with the same id and timestamp. ok is false if the log was not
+// written because of the settings of the logger. The loggers of the package
+// are matched by their exact type, so that the types which embed them and
+// override the methods are not bypassed. For the other loggers, the log is
+// created from the properties of the logger.
+func recordLog(logger Logger, level LogLevel, msg string, lf ...LogFields) (Log, bool, error) {
+	switch lg := logger.(type) {
+	case *ConsoleLogger:
+		return lg.record(level, msg, lf...)
+	case *MemoryLogger:
+		return lg.record(level, msg, lf...)
+	case *FileLogger:
+		return lg.record(level, msg, lf...)
+	case *MongoLogger:
+		return lg.record(level, msg, lf...)
+	case *SlogLogger:
+		return lg.record(level, msg, lf...)
+	case *MultiLogger:
+		return lg.record(level, msg, lf...)
+	case *BroadcastLogger:
+		return lg.record(level, msg, lf...)
+	}
+
+	err := logWithLevel(logger, level, msg, lf...)
+
+	props := logger.GetProps()
+	if !props.Settings.Enabled(level, props.Source) {
+		return Log{}, false, err
+	}
+
+	return newLog(props.Settings, level, msg, props.Source, props.Event, props.EventID, mergeFields(props.Fields, lf...)), true, err
+}
+
+// logWithLevel calls the method of the logger which matches the level.
+func logWithLevel(logger Logger, level LogLevel, msg string, lf ...LogFields) error {
+	switch level {
+	case TRACE:
+		return logger.Trace(msg, lf...)
+	case DEBUG:
+		return logger.Debug(msg, lf...)
+	case INFO:
+		return logger.Info(msg, lf...)
+	case WARN:
+		return logger.Warn(msg, lf...)
+	case ERROR:
+		return logger.Error(msg, lf...)
+	case FATAL:
+		return logger.Fatal(msg, lf...)
+	default:
+		return fmt.Errorf("invalid log level: %v", level)
+	}
+}
+
+// writeLogs stores the logs with a batch write if the logger implements the
+// LogWriter interface. Otherwise the logs are written one by one, using
+// the properties of the logs.
+func writeLogs(logger Logger, logs []Log) error {
+	if w, ok := logger.(LogWriter); ok {
+		return w.WriteLogs(logs)
+	}
+
+	eg := NewErrGroup(ErrGroupProps{ID: "failed to write logs"})
+	for _, log := range logs {
+		var lf []LogFields
+		if len(log.Fields) > 0 {
+			lf = append(lf, log.Fields)
+		}
+
+		l := logger.WithSource(log.Source).WithEvent(log.Event).WithEventID(log.EventID)
+		eg.Add(logWithLevel(l, log.Level, log.Message, lf...))
+	}
+
+	return eg.ToErr()
+}
+
+// enabledLogs returns the logs which are enabled by the settings, with the
+// redaction of the settings applied.
+func enabledLogs(settings *LoggerSettings, logs []Log) []Log {
+	enabled := make([]Log, 0, len(logs))
+	for _, log := range logs {
+		if settings.Enabled(log.Level, log.Source) {
+			enabled = append(enabled, settings.Redact(log))
+		}
+	}
+
+	return enabled
+}
+
+// newLog creates the log of the logger, with the caller, the stack trace and
+// the redaction of the settings applied.
+func newLog(settings *LoggerSettings, level LogLevel, msg, source, event, eventID string, fields LogFields) Log {
+	log := NewLog(level, msg, source, event, eventID, fields)
+	if settings == nil {
+		return log
+	}
+
+	if settings.ShowCaller || settings.CaptureCaller {
+		log.Caller = captureCaller()
+	}
+
+	if settings.CaptureStack && level >= ERROR {
+		log.Stack = captureStack()
+	}
+
+	return settings.Redact(log)
+}
+
 func NewLog(level LogLevel, msg, source, event, eventID string, fields ...LogFields) Log {
 	log := Log{
 		Timestamp: time.Now().UTC(),
@@ -110,51 +353,27 @@ func NewLog(level LogLevel, msg, source, event, eventID string, fields ...LogFie
 	return log
 }
 
-// String method converts the log to a string, using the provided logger settings.
+// String method converts the log to a string, using the format and the other
+// console settings of the logger. The default settings are used if the
+// logger or its settings are nil.
 func (log Log) String(logger Logger) string {
-	// Use the default settings by default if the settings are not correct
 	settings := DefaultLoggerSettings
-
-	// if the logger is not nil and has it has settings with a defined location, use them
 	if logger != nil {
-		props := logger.GetProps()
-
-		if props.Settings != nil && props.Settings.Location != nil {
+		if props := logger.GetProps(); props.Settings != nil {
 			settings = props.Settings
 		}
 	}
 
-	var b strings.Builder
-
-	timeformat := settings.TimeFormat
-	if timeformat == "" {
-		timeformat = defaultTimeFormat
-	}
-
-	b.WriteString(log.Timestamp.In(settings.Location).Format(timeformat))
-	b.WriteString("  ")
-	b.WriteString(fmt.Sprintf("%-6s", log.Level.String()))
-	b.WriteString("  ")
-	b.WriteString(fmt.Sprintf("%-12s", log.Source))
-	b.WriteString(fmt.Sprintf("%-12s", log.Event))
-	b.WriteString("  ")
-	b.WriteString(log.Message)
-
-	if log.Fields != nil {
-
-		b.WriteString("  ")
-
-		for k, v := range log.Fields {
-			b.WriteString(" ")
-			b.WriteString(k)
-			b.WriteString("=")
-			b.WriteString(fmt.Sprintf("%v", v))
-		}
+	switch settings.consoleFormat() {
+	case ConsoleLogfmt:
+		return log.formatLogfmt(settings)
+	case ConsoleJSON:
+		return log.formatJSON(settings)
+	case ConsoleColor:
+		return log.formatText(settings, true)
+	default:
+		return log.formatText(settings, false)
 	}
-
-	b.WriteString("\n")
-
-	return b.String()
 }
 
 type LoggerProps struct {
@@ -162,6 +381,7 @@ type LoggerProps struct {
 	Source   string
 	Event    string
 	EventID  string
+	Fields   LogFields
 }
 
 // ----------- Logger implementation for console -----------
@@ -171,6 +391,7 @@ type ConsoleLogger struct {
 	Source   string
 	Event    string
 	EventID  string
+	Fields   LogFields // Default fields which are added to every log
 }
 
 func NewConsoleLogger(s *LoggerSettings) *ConsoleLogger { return &ConsoleLogger{Settings: s} }
@@ -181,6 +402,7 @@ func (lg *ConsoleLogger) GetProps() LoggerProps {
 		Source:   lg.Source,
 		Event:    lg.Event,
 		EventID:  lg.EventID,
+		Fields:   lg.Fields,
 	}
 }
 
@@ -189,24 +411,49 @@ func (lg *ConsoleLogger) Name() string { return "console" }
 func (lg *ConsoleLogger) GetTableName() string { return "" }
 
 func (lg *ConsoleLogger) log(level LogLevel, msg string, lf ...LogFields) error {
-	log := NewLog(level, msg, lg.Source, lg.Event, lg.EventID, lf...)
-	_, err := fmt.Print(log.String(lg))
+	_, _, err := lg.record(level, msg, lf...)
 	return err
 }
 
+func (lg *ConsoleLogger) record(level LogLevel, msg string, lf ...LogFields) (Log, bool, error) {
+	if !lg.Settings.Enabled(level, lg.Source) {
+		return Log{}, false, nil
+	}
+
+	log := newLog(lg.Settings, level, msg, lg.Source, lg.Event, lg.EventID, mergeFields(lg.Fields, lf...))
+	_, err := fmt.Print(log.String(lg))
+	return log, true, err
+}
+
+// clone returns a shallow copy of the logger, so that the With* methods
+// do not modify the logger which may be shared between goroutines.
+func (lg *ConsoleLogger) clone() *ConsoleLogger {
+	c := *lg
+	return &c
+}
+
 func (lg *ConsoleLogger) WithSource(v string) Logger {
-	lg.Source = v
-	return lg
+	c := lg.clone()
+	c.Source = v
+	return c
 }
 
 func (lg *ConsoleLogger) WithEvent(v string) Logger {
-	lg.Event = v
-	return lg
+	c := lg.clone()
+	c.Event = v
+	return c
 }
 
 func (lg *ConsoleLogger) WithEventID(v string) Logger {
-	lg.EventID = v
-	return lg
+	c := lg.clone()
+	c.EventID = v
+	return c
+}
+
+func (lg *ConsoleLogger) With(fields LogFields) Logger {
+	c := lg.clone()
+	c.Fields = mergeFields(lg.Fields, fields)
+	return c
 }
 
 func (lg *ConsoleLogger) Debug(msg string, lf ...LogFields) error { return lg.log(DEBUG, msg, lf...) }
@@ -0,0 +1,433 @@
+package syro
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TaskQueue runs one-off tasks (handler + payload) at a specified time. The
+// tasks are stored using the TaskStorage interface, so they survive the
+// restarts of the app. Workers claim the due tasks with a lease, which
+// allows other workers to pick up the task if the worker crashes.
+//
+// If the history storage is specified, each attempt is registered as a
+// CronExecLog, where the name is the handler and the run id is the
+// id of the task.
+type TaskQueue struct {
+	Source       string        // Source is used to identify the source of the tasks
+	Storage      TaskStorage   // Storage of the tasks
+	History      CronStorage   // Optional. Storage of the execution logs
+	Logger       Logger        // Optional. Used to log the errors of the workers
+	MaxAttempts  int           // Max number of attempts before the task is moved to the dead status
+	RetryDelay   time.Duration // Pause before the next attempt, multiplied by the number of attempts
+	Lease        time.Duration // For how long a worker owns the claimed task
+	Timeout      time.Duration // Optional. Timeout of the handler, 0 means no timeout. Should be shorter than the Lease, so that the task is not claimed again while it is running
+	PollInterval time.Duration // How often the workers check for new tasks if the queue is empty
+	Workers      int           // Number of workers started by Start
+
+	mu       sync.RWMutex
+	handlers map[string]TaskHandler
+}
+
+// TaskHandler is the function which is executed for the task.
+type TaskHandler func(ctx context.Context, payload []byte) error
+
+type TaskStorage interface {
+	// EnqueueTask stores the new task
+	EnqueueTask(t *Task) error
+	// ClaimTask sets the next due task of the source to running and returns
+	// it. Tasks with an expired lease can be claimed again. If there are
+	// no tasks to claim, nil is returned.
+	ClaimTask(source, workerID string, now time.Time, lease time.Duration) (*Task, error)
+	// UpdateTask stores the result of the attempt. The update is only applied
+	// if the task is still owned by the worker of the task.
+	UpdateTask(t *Task) error
+	// FindTasks returns a list of tasks that match the filter, sorted by the
+	// run_at in descending order. The From and To can be used separately
+	// and a limit of 0 means no limit.
+	FindTasks(filter TaskFilter, maxLimit int64) ([]Task, error)
+}
+
+// Task stores information about the one-off task.
+type Task struct {
+	ID          string     `json:"_id" bson:"_id"`
+	Source      string     `json:"source" bson:"source"`
+	Handler     string     `json:"handler" bson:"handler"`
+	Payload     []byte     `json:"payload" bson:"payload"`
+	Status      TaskStatus `json:"status" bson:"status"`
+	RunAt       time.Time  `json:"run_at" bson:"run_at"`
+	Attempts    int        `json:"attempts" bson:"attempts"`
+	MaxAttempts int        `json:"max_attempts" bson:"max_attempts"`
+	WorkerID    string     `json:"worker_id" bson:"worker_id"`
+	LeaseUntil  time.Time  `json:"lease_until" bson:"lease_until"`
+	Error       string     `json:"error" bson:"error"`
+	CreatedAt   time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+// TaskFilter is used to filter the tasks. The From and To fields of the
+// TimeseriesFilter are applied to the run_at field.
+type TaskFilter struct {
+	TimeseriesFilter `json:"timeseries_filter" bson:"timeseries_filter"`
+	Source           string     `json:"source" bson:"source"`
+	Handler          string     `json:"handler" bson:"handler"`
+	Status           TaskStatus `json:"status" bson:"status"`
+}
+
+type TaskStatus string
+
+const (
+	TaskStatusPending TaskStatus = "pending" // tasks which are waiting for the run_at time or a retry
+	TaskStatusRunning TaskStatus = "running" // tasks which are claimed by a worker
+	TaskStatusDone    TaskStatus = "done"    // tasks which finished without an error
+	TaskStatusDead    TaskStatus = "dead"    // tasks which failed after all of the attempts
+)
+
+func NewTaskQueue(storage TaskStorage, source string) *TaskQueue {
+	return &TaskQueue{
+		Source:       source,
+		Storage:      storage,
+		MaxAttempts:  3,
+		RetryDelay:   10 * time.Second,
+		Lease:        5 * time.Minute,
+		Timeout:      5 * time.Minute,
+		PollInterval: time.Second,
+		Workers:      1,
+		handlers:     make(map[string]TaskHandler),
+	}
+}
+
+// WithHistory sets the storage for the execution logs of the tasks.
+func (q *TaskQueue) WithHistory(storage CronStorage) *TaskQueue {
+	q.History = storage
+	return q
+}
+
+// WithLogger sets the logger for the errors of the workers.
+func (q *TaskQueue) WithLogger(logger Logger) *TaskQueue {
+	q.Logger = logger
+	return q
+}
+
+// Handle binds the handler to the name, which is used when enqueuing the tasks.
+func (q *TaskQueue) Handle(name string, fn TaskHandler) *TaskQueue {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// the queue can be created without NewTaskQueue
+	if q.handlers == nil {
+		q.handlers = make(map[string]TaskHandler)
+	}
+
+	q.handlers[name] = fn
+	return q
+}
+
+// EnqueueAt stores a task which runs the handler with the JSON encoded
+// payload at the provided time.
+func (q *TaskQueue) EnqueueAt(handler string, payload any, at time.Time) (*Task, error) {
+	if q.Storage == nil {
+		return nil, fmt.Errorf("task storage cannot be nil")
+	}
+
+	if handler == "" {
+		return nil, fmt.Errorf("handler has to be specified")
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %v", err)
+	}
+
+	now := time.Now().UTC()
+	t := &Task{
+		ID:          newRandomID(),
+		Source:      q.Source,
+		Handler:     handler,
+		Payload:     data,
+		Status:      TaskStatusPending,
+		RunAt:       at.UTC(),
+		MaxAttempts: max(q.MaxAttempts, 1),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := q.Storage.EnqueueTask(t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// EnqueueAfter stores a task which runs the handler with the JSON encoded
+// payload after the provided duration.
+func (q *TaskQueue) EnqueueAfter(handler string, payload any, d time.Duration) (*Task, error) {
+	return q.EnqueueAt(handler, payload, time.Now().Add(d))
+}
+
+// Start starts the workers, which poll the storage until the context is
+// cancelled.
+func (q *TaskQueue) Start(ctx context.Context) {
+	for i := 0; i < max(q.Workers, 1); i++ {
+		workerID := fmt.Sprintf("%v-%v", newRandomID(), i)
+
+		go func() {
+			ticker := time.NewTicker(q.PollInterval)
+			defer ticker.Stop()
+
+			for {
+				// process all of the due tasks before waiting for the next tick
+				for {
+					processed, err := q.processNext(ctx, workerID)
+					if err != nil && q.Logger != nil {
+						q.Logger.Error("failed to process task", LogFields{
+							"source": q.Source,
+							"worker": workerID,
+							"error":  err.Error(),
+						})
+					}
+
+					if !processed || err != nil || ctx.Err() != nil {
+						break
+					}
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// processNext claims and executes a single task. Returns false if there were
+// no tasks to claim.
+func (q *TaskQueue) processNext(ctx context.Context, workerID string) (bool, error) {
+	t, err := q.Storage.ClaimTask(q.Source, workerID, time.Now().UTC(), q.Lease)
+	if err != nil || t == nil {
+		return false, err
+	}
+
+	start := time.Now()
+
+	var taskErr error
+	if t.Attempts > t.MaxAttempts {
+		// the lease of the last attempt expired before the worker finished
+		taskErr = fmt.Errorf("lease of the last attempt expired")
+	} else {
+		taskErr = q.run(ctx, t)
+	}
+
+	now := time.Now().UTC()
+	t.UpdatedAt = now
+	t.Error = ""
+
+	switch {
+	case taskErr == nil:
+		t.Status = TaskStatusDone
+	case t.Attempts >= t.MaxAttempts:
+		t.Status = TaskStatusDead
+		t.Error = taskErr.Error()
+	default:
+		t.Status = TaskStatusPending
+		t.Error = taskErr.Error()
+		t.RunAt = now.Add(q.RetryDelay * time.Duration(t.Attempts))
+	}
+
+	if q.History != nil {
+		if err := q.History.RegisterExecution(newCronExecutionLog(t.Source, t.Handler, t.ID, start, taskErr)); err != nil && q.Logger != nil {
+			q.Logger.Error("failed to register task execution", LogFields{
+				"source": t.Source,
+				"id":     t.ID,
+				"error":  err.Error(),
+			})
+		}
+	}
+
+	return true, q.Storage.UpdateTask(t)
+}
+
+// run executes the handler of the task with the timeout of the queue.
+func (q *TaskQueue) run(ctx context.Context, t *Task) (err error) {
+	q.mu.RLock()
+	handler, ok := q.handlers[t.Handler]
+	q.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("handler %v is not registered", t.Handler)
+	}
+
+	if q.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.Timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+
+	return handler(contextWithJobRunID(ctx, t.ID), t.Payload)
+}
+
+// ----------- TaskStorage implementation for memory -----------
+
+// MemoryTaskStorage stores the tasks in memory. Useful for tests and for
+// apps which do not need the tasks to survive the restarts.
+type MemoryTaskStorage struct {
+	mu    sync.Mutex
+	tasks map[string]*Task
+}
+
+var _ TaskStorage = (*MemoryTaskStorage)(nil)
+
+func NewMemoryTaskStorage() *MemoryTaskStorage {
+	return &MemoryTaskStorage{tasks: make(map[string]*Task)}
+}
+
+func (m *MemoryTaskStorage) EnqueueTask(t *Task) error {
+	if t == nil {
+		return fmt.Errorf("task cannot be nil")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tasks[t.ID]; ok {
+		return fmt.Errorf("task with id %v already exists", t.ID)
+	}
+
+	task := *t
+	m.tasks[t.ID] = &task
+	return nil
+}
+
+func (m *MemoryTaskStorage) ClaimTask(source, workerID string, now time.Time, lease time.Duration) (*Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var next *Task
+	for _, t := range m.tasks {
+		if t.Source != source || !t.claimable(now) {
+			continue
+		}
+
+		if next == nil || t.RunAt.Before(next.RunAt) {
+			next = t
+		}
+	}
+
+	if next == nil {
+		return nil, nil
+	}
+
+	next.Status = TaskStatusRunning
+	next.WorkerID = workerID
+	next.LeaseUntil = now.Add(lease)
+	next.Attempts++
+	next.UpdatedAt = now
+
+	task := *next
+	return &task, nil
+}
+
+func (m *MemoryTaskStorage) UpdateTask(t *Task) error {
+	if t == nil {
+		return fmt.Errorf("task cannot be nil")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.tasks[t.ID]
+	if !ok {
+		return fmt.Errorf("task with id %v does not exist", t.ID)
+	}
+
+	if existing.WorkerID != t.WorkerID || existing.Status != TaskStatusRunning {
+		return fmt.Errorf("task %v is no longer owned by the worker", t.ID)
+	}
+
+	task := *t
+	m.tasks[t.ID] = &task
+	return nil
+}
+
+func (m *MemoryTaskStorage) FindTasks(filter TaskFilter, maxLimit int64) ([]Task, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return nil, fmt.Errorf("from date cannot be after to date")
+	}
+
+	m.mu.Lock()
+	docs := make([]Task, 0)
+	for _, t := range m.tasks {
+		if filter.matches(t) {
+			docs = append(docs, *t)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].RunAt.After(docs[j].RunAt) })
+
+	userLimit := filter.Limit
+	if userLimit > maxLimit {
+		userLimit = maxLimit
+	}
+
+	if filter.Skip >= int64(len(docs)) {
+		return []Task{}, nil
+	}
+
+	// same as in mongo, a limit of 0 means no limit
+	docs = docs[filter.Skip:]
+	if userLimit > 0 && int64(len(docs)) > userLimit {
+		docs = docs[:userLimit]
+	}
+
+	return docs, nil
+}
+
+// claimable returns true if the task is due or if the lease of the worker
+// which claimed the task has expired.
+func (t *Task) claimable(now time.Time) bool {
+	switch t.Status {
+	case TaskStatusPending:
+		return !t.RunAt.After(now)
+	case TaskStatusRunning:
+		return t.LeaseUntil.Before(now)
+	default:
+		return false
+	}
+}
+
+func (f TaskFilter) matches(t *Task) bool {
+	if f.Source != "" && t.Source != f.Source {
+		return false
+	}
+
+	if f.Handler != "" && t.Handler != f.Handler {
+		return false
+	}
+
+	if f.Status != "" && t.Status != f.Status {
+		return false
+	}
+
+	if !f.From.IsZero() && t.RunAt.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && t.RunAt.After(f.To) {
+		return false
+	}
+
+	return true
+}
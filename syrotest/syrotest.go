@@ -0,0 +1,171 @@
+// Package syrotest provides a syro.Logger which records the logs, so that the
+// code which takes a logger can be tested without hand-rolled mocks.
+package syrotest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tompston/syro"
+)
+
+// Logger is a syro.Logger which records the logs in memory. The child loggers
+// created with the With* methods share the recorded logs with the parent.
+type Logger struct {
+	syro.Logger
+	mem  *syro.MemoryLogger
+	tb   testing.TB
+	opts Options
+}
+
+var _ syro.Logger = (*Logger)(nil)
+
+// Options are the optional settings of the test Logger.
+type Options struct {
+	Size      int                  // Optional. Max number of recorded logs, defaults to 1000
+	Settings  *syro.LoggerSettings // Optional. Defaults to settings without console output
+	LogToTest bool                 // Optional. Write the logs with t.Log, so that they are shown for failed tests
+}
+
+// NewLogger creates a recording logger for the test.
+func NewLogger(t testing.TB, opts *Options) *Logger {
+	l := &Logger{tb: t}
+	if opts != nil {
+		l.opts = *opts
+	}
+
+	if l.opts.Settings == nil {
+		l.opts.Settings = &syro.LoggerSettings{DisableConsole: true}
+	}
+
+	l.mem = syro.NewMemoryLogger(l.opts.Size, l.opts.Settings)
+	l.Logger = l.mem
+	return l
+}
+
+// Logs returns all of the recorded logs, from the oldest to the newest.
+func (l *Logger) Logs() []syro.Log { return l.mem.Logs() }
+
+// Reset removes all of the recorded logs.
+func (l *Logger) Reset() { l.mem.Reset() }
+
+// AssertLogged fails the test if there is no log with the level, a message
+// which contains msgSubstr and the provided fields. Fields of the log
+// which are not in the provided fields are ignored.
+func (l *Logger) AssertLogged(t testing.TB, level syro.LogLevel, msgSubstr string, fields syro.LogFields) {
+	t.Helper()
+
+	logs := l.Logs()
+	for _, log := range logs {
+		if log.Level == level && strings.Contains(log.Message, msgSubstr) && hasFields(log, fields) {
+			return
+		}
+	}
+
+	t.Errorf("expected a %v log with the message %q and fields %v, recorded logs:\n%v", level, msgSubstr, fields, formatLogs(logs))
+}
+
+// AssertNotLogged fails the test if there is a log with the level and a
+// message which contains msgSubstr.
+func (l *Logger) AssertNotLogged(t testing.TB, level syro.LogLevel, msgSubstr string) {
+	t.Helper()
+
+	for _, log := range l.Logs() {
+		if log.Level == level && strings.Contains(log.Message, msgSubstr) {
+			t.Errorf("unexpected %v log: %v", level, log.Message)
+		}
+	}
+}
+
+// AssertNoErrors fails the test if there are logs with the ERROR or FATAL level.
+func (l *Logger) AssertNoErrors(t testing.TB) {
+	t.Helper()
+
+	var errs []syro.Log
+	for _, log := range l.Logs() {
+		if log.Level >= syro.ERROR {
+			errs = append(errs, log)
+		}
+	}
+
+	if len(errs) > 0 {
+		t.Errorf("expected no error logs, got %v:\n%v", len(errs), formatLogs(errs))
+	}
+}
+
+func (l *Logger) child(inner syro.Logger) *Logger {
+	c := *l
+	c.Logger = inner
+	return &c
+}
+
+func (l *Logger) WithSource(v string) syro.Logger        { return l.child(l.Logger.WithSource(v)) }
+func (l *Logger) WithEvent(v string) syro.Logger         { return l.child(l.Logger.WithEvent(v)) }
+func (l *Logger) WithEventID(v string) syro.Logger       { return l.child(l.Logger.WithEventID(v)) }
+func (l *Logger) With(fields syro.LogFields) syro.Logger { return l.child(l.Logger.With(fields)) }
+
+func (l *Logger) log(level syro.LogLevel, msg string, lf ...syro.LogFields) error {
+	if l.opts.LogToTest {
+		props := l.GetProps()
+		if props.Settings.Enabled(level, props.Source) {
+			fields := syro.LogFields{}
+			for _, f := range append([]syro.LogFields{props.Fields}, lf...) {
+				for k, v := range f {
+					fields[k] = v
+				}
+			}
+
+			log := props.Settings.Redact(syro.NewLog(level, msg, props.Source, props.Event, props.EventID, fields))
+			l.tb.Log(strings.TrimSuffix(log.String(l), "\n"))
+		}
+	}
+
+	switch level {
+	case syro.TRACE:
+		return l.Logger.Trace(msg, lf...)
+	case syro.DEBUG:
+		return l.Logger.Debug(msg, lf...)
+	case syro.INFO:
+		return l.Logger.Info(msg, lf...)
+	case syro.WARN:
+		return l.Logger.Warn(msg, lf...)
+	case syro.ERROR:
+		return l.Logger.Error(msg, lf...)
+	default:
+		return l.Logger.Fatal(msg, lf...)
+	}
+}
+
+func (l *Logger) Debug(msg string, lf ...syro.LogFields) error { return l.log(syro.DEBUG, msg, lf...) }
+func (l *Logger) Trace(msg string, lf ...syro.LogFields) error { return l.log(syro.TRACE, msg, lf...) }
+func (l *Logger) Error(msg string, lf ...syro.LogFields) error { return l.log(syro.ERROR, msg, lf...) }
+func (l *Logger) Info(msg string, lf ...syro.LogFields) error  { return l.log(syro.INFO, msg, lf...) }
+func (l *Logger) Warn(msg string, lf ...syro.LogFields) error  { return l.log(syro.WARN, msg, lf...) }
+func (l *Logger) Fatal(msg string, lf ...syro.LogFields) error { return l.log(syro.FATAL, msg, lf...) }
+
+// hasFields checks if the log has all of the provided fields.
+func hasFields(log syro.Log, fields syro.LogFields) bool {
+	for k, v := range fields {
+		got, ok := log.Fields[k]
+		if !ok || !reflect.DeepEqual(got, v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatLogs(logs []syro.Log) string {
+	if len(logs) == 0 {
+		return "  (none)"
+	}
+
+	var b strings.Builder
+	for _, log := range logs {
+		fmt.Fprintf(&b, "  %-6s %q source=%q event=%q fields=%v\n", log.Level, log.Message, log.Source, log.Event, log.Fields)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,68 @@
+package syrotest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tompston/syro"
+)
+
+// recordingTB captures the errors of the assertions, so that the failures
+// can be tested.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestLogger(t *testing.T) {
+	logger := NewLogger(t, &Options{LogToTest: true})
+
+	var lg syro.Logger = logger
+	api := lg.WithSource("api").With(syro.LogFields{"user": "john"})
+
+	if err := api.Info("request handled", syro.LogFields{"status": 200}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := api.WithEvent("auth").Warn("token expires soon"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logger.Logs()) != 2 {
+		t.Fatalf("expected the child loggers to record to the parent, got %v logs", len(logger.Logs()))
+	}
+
+	t.Run("test-passing-assertions", func(t *testing.T) {
+		logger.AssertLogged(t, syro.INFO, "handled", syro.LogFields{"status": 200, "user": "john"})
+		logger.AssertLogged(t, syro.WARN, "expires", nil)
+		logger.AssertNotLogged(t, syro.ERROR, "request")
+		logger.AssertNoErrors(t)
+	})
+
+	t.Run("test-failing-assertions", func(t *testing.T) {
+		if err := api.Error("request failed"); err != nil {
+			t.Fatal(err)
+		}
+
+		rec := &recordingTB{TB: t}
+		logger.AssertLogged(rec, syro.INFO, "handled", syro.LogFields{"status": 500})
+		logger.AssertLogged(rec, syro.DEBUG, "handled", nil)
+		logger.AssertNotLogged(rec, syro.ERROR, "failed")
+		logger.AssertNoErrors(rec)
+
+		if len(rec.errors) != 4 {
+			t.Fatalf("expected 4 failed assertions, got %v: %v", len(rec.errors), rec.errors)
+		}
+	})
+
+	logger.Reset()
+	if len(logger.Logs()) != 0 {
+		t.Fatal("expected no logs after reset")
+	}
+}